@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// syslogAccessLog is the BLOG_API_ACCESS_LOG value sending the access log
+// to the local syslog.
+const syslogAccessLog = "syslog"
+
+// openAccessLog returns the destination of the access log configured by
+// cfg, nil when it is written with the other logs.
+func openAccessLog(cfg *config) (io.WriteCloser, error) {
+	switch cfg.AccessLog {
+	case "":
+		return nil, nil
+	case syslogAccessLog:
+		return openSyslog()
+	}
+	// The file is opened once to report a bad path at startup rather
+	// than on the first request.
+	f, err := os.OpenFile(cfg.AccessLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &lumberjack.Logger{
+		Filename:   cfg.AccessLog,
+		MaxSize:    cfg.AccessLogMaxSize,
+		MaxBackups: cfg.AccessLogKeep,
+		Compress:   true,
+	}, nil
+}
+
+// rotateAccessLog starts a new access log file every interval, until
+// stop is closed.
+func rotateAccessLog(l *lumberjack.Logger, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		err := l.Rotate()
+		if err != nil {
+			slog.Error("fail to rotate access log", "err", err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// backuper is implemented by the stores able to write a consistent
+// snapshot of their data.
+type backuper interface {
+	// Backup writes a snapshot of the store to w.
+	Backup(w io.Writer) (int64, error)
+}
+
+// compacter is implemented by the stores able to reclaim unused space.
+type compacter interface {
+	// Compact compacts the store and returns its size in bytes before
+	// and after.
+	Compact() (before, after int64, err error)
+}
+
+func (s *server) backupHandler(w http.ResponseWriter, r *http.Request) {
+	b, ok := baseStore(s.store).(backuper)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "backup not supported by the store")
+		return
+	}
+
+	// A large database takes longer to download than the write timeout.
+	clearWriteDeadline(w, r)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, backupName(time.Now())))
+	_, err := b.Backup(w)
+	if err != nil {
+		// The headers are already sent, the client sees a truncated file.
+		slog.ErrorContext(r.Context(), "fail to backup DB", "err", err)
+	}
+}
+
+func (s *server) compactHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := baseStore(s.store).(compacter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "compaction not supported by the store")
+		return
+	}
+
+	before, after, err := c.Compact()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to compact DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to compact DB")
+		return
+	}
+	writeResponse(w, r, struct {
+		Before    int64 `json:"before"`
+		After     int64 `json:"after"`
+		Reclaimed int64 `json:"reclaimed"`
+	}{
+		Before:    before,
+		After:     after,
+		Reclaimed: before - after,
+	})
+}
+
+func (s *server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	clearWriteDeadline(w, r)
+	name := fmt.Sprintf("blog-%s.ndjson", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	_, err := exportArticles(s.storeOf(r), w)
+	if err != nil {
+		// The headers are already sent, the client sees a truncated file.
+		slog.ErrorContext(r.Context(), "fail to export DB", "err", err)
+	}
+}
+
+func (s *server) importHandler(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/x-ndjson" {
+		writeError(w, http.StatusBadRequest, "invalid content-type")
+		return
+	}
+
+	n, err := importArticles(s.storeOf(r), r.Body)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to import DB", "err", err)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("fail to import after %d articles: %v", n, err))
+		return
+	}
+	writeResponse(w, r, struct {
+		Imported int `json:"imported"`
+	}{
+		Imported: n,
+	})
+}
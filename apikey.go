@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+)
+
+var errUnknownKey = errors.New("unknown key")
+
+// apiKeysBucket holds the API keys by hash of their secret.
+var apiKeysBucket = []byte("_api_keys")
+
+// The roles of the API keys.
+const (
+	// roleAdmin keys are accepted everywhere, like the admin key.
+	roleAdmin = "admin"
+	// roleEditor keys modify the articles of every user, the keys
+	// created without a role are editor keys.
+	roleEditor = "editor"
+	// roleAuthor keys modify the articles of their user only.
+	roleAuthor = "author"
+)
+
+// apiKey is a key allowed to modify the articles. Only the hash of its
+// secret is stored, the secret is sent once when the key is created.
+type apiKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Role is admin, editor or author, User is the user of an author.
+	Role    string    `json:"role,omitempty"`
+	User    string    `json:"user,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// keyStore is implemented by the stores able to keep API keys.
+type keyStore interface {
+	// PutKey stores k under the hash of its secret.
+	PutKey(hash string, k *apiKey) error
+	// KeyByHash returns the key with the secret hash.
+	KeyByHash(hash string) (*apiKey, error)
+	// Keys returns the keys, the oldest first.
+	Keys() ([]*apiKey, error)
+	// DeleteKey revokes the key id.
+	DeleteKey(id string) error
+}
+
+// hashKey returns the hash of the secret of a key.
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newKeySecret returns the ID and the secret of a new key.
+func newKeySecret() (id, secret string, err error) {
+	buf := make([]byte, 40)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(buf[:8]), base64.RawURLEncoding.EncodeToString(buf[8:]), nil
+}
+
+// newAPIKey returns a new key of the user with the role and its secret,
+// an editor key when the role is empty.
+func newAPIKey(name, user, role string) (*apiKey, string, error) {
+	name, user, role = strings.TrimSpace(name), strings.TrimSpace(user), strings.TrimSpace(role)
+	switch role {
+	case "":
+		role = roleEditor
+	case roleAdmin, roleEditor, roleAuthor:
+	default:
+		return nil, "", invalidError("invalid role, expected admin, editor or author")
+	}
+	if role == roleAuthor && user == "" {
+		return nil, "", invalidError("missing user of the author key")
+	}
+	if role != roleAuthor && user != "" {
+		return nil, "", invalidError("only the author keys have a user")
+	}
+	id, secret, err := newKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+	k := &apiKey{
+		ID:      id,
+		Name:    name,
+		Role:    role,
+		User:    user,
+		Created: time.Now().UTC(),
+	}
+	return k, secret, nil
+}
+
+// hasKeys reports whether keys holds an API key.
+func hasKeys(keys keyStore) (bool, error) {
+	list, err := keys.Keys()
+	if err != nil {
+		return false, err
+	}
+	return len(list) > 0, nil
+}
+
+// postKeyHandler issues a new API key.
+func (s *server) postKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keys, ok := baseStore(s.store).(keyStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "API keys not supported by the store")
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+		User string `json:"user"`
+	}
+	if r.ContentLength != 0 {
+		err := decodeRequest(r, &req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	k, secret, err := newAPIKey(req.Name, req.User, req.Role)
+	if e, ok := err.(invalidError); ok {
+		writeError(w, http.StatusBadRequest, e.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to generate key", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to generate key")
+		return
+	}
+	err = keys.PutKey(hashKey(secret), k)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	writeResponse(w, r, struct {
+		*apiKey
+		Key string `json:"key"`
+	}{k, secret})
+}
+
+// getKeysHandler lists the API keys, without their secret.
+func (s *server) getKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, ok := baseStore(s.store).(keyStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "API keys not supported by the store")
+		return
+	}
+
+	list, err := keys.Keys()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	if list == nil {
+		list = []*apiKey{}
+	}
+	writeResponse(w, r, list)
+}
+
+// deleteKeyHandler revokes an API key.
+func (s *server) deleteKeyHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["key"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing key")
+		return
+	}
+	keys, ok := baseStore(s.store).(keyStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "API keys not supported by the store")
+		return
+	}
+
+	err := keys.DeleteKey(id)
+	if err == errUnknownKey {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+}
+
+func (s *boltStore) PutKey(hash string, k *apiKey) error {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(apiKeysBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(hash), data)
+	})
+}
+
+func (s *boltStore) KeyByHash(hash string) (*apiKey, error) {
+	k := &apiKey{}
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(apiKeysBucket)
+		if b == nil {
+			return errUnknownKey
+		}
+		data := b.Get([]byte(hash))
+		if data == nil {
+			return errUnknownKey
+		}
+		return json.Unmarshal(data, k)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (s *boltStore) Keys() ([]*apiKey, error) {
+	var keys []*apiKey
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(apiKeysBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			k := &apiKey{}
+			err := json.Unmarshal(v, k)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortKeys(keys)
+	return keys, nil
+}
+
+func (s *boltStore) DeleteKey(id string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(apiKeysBucket)
+		if b == nil {
+			return errUnknownKey
+		}
+		var hash []byte
+		err := b.ForEach(func(h, v []byte) error {
+			k := &apiKey{}
+			err := json.Unmarshal(v, k)
+			if err != nil {
+				return err
+			}
+			if k.ID == id {
+				hash = append([]byte(nil), h...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if hash == nil {
+			return errUnknownKey
+		}
+		return b.Delete(hash)
+	})
+}
+
+func (s *memoryStore) PutKey(hash string, k *apiKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil {
+		s.keys = make(map[string]apiKey)
+	}
+	s.keys[hash] = *k
+	return nil
+}
+
+func (s *memoryStore) KeyByHash(hash string) (*apiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[hash]
+	if !ok {
+		return nil, errUnknownKey
+	}
+	return &k, nil
+}
+
+func (s *memoryStore) Keys() ([]*apiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []*apiKey
+	for _, k := range s.keys {
+		k := k
+		keys = append(keys, &k)
+	}
+	sortKeys(keys)
+	return keys, nil
+}
+
+func (s *memoryStore) DeleteKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, k := range s.keys {
+		if k.ID == id {
+			delete(s.keys, hash)
+			return nil
+		}
+	}
+	return errUnknownKey
+}
+
+// sortKeys sorts keys by creation time, the oldest first.
+func sortKeys(keys []*apiKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Created.Before(keys[j].Created)
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// archiveMonth is the number of articles created during a month.
+type archiveMonth struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	Count int `json:"count"`
+}
+
+// parseMonth returns the UTC time range [start, end) of a month given
+// as a year and a month number.
+func parseMonth(year, month string) (start, end time.Time, err error) {
+	y, err := strconv.Atoi(year)
+	if err != nil || y < 1 || y > 9999 {
+		return start, end, errors.New("invalid year")
+	}
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 {
+		return start, end, errors.New("invalid month")
+	}
+	start = time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// getArchiveHandler returns the number of articles per month, the most
+// recent month first. The listing filters apply.
+func (s *server) getArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	q, err := parseListQuery(r, "desc")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.checkListStatus(w, r, id, q) {
+		return
+	}
+
+	months := []*archiveMonth{}
+	err = s.storeOf(r).Walk(id, q.order, nil, func(a *article) bool {
+		if q.done(a) {
+			return false
+		}
+		if !q.match(a) {
+			return true
+		}
+		t := a.Timestamp.UTC()
+		// The walk goes back in time, a month only follows itself.
+		last := len(months) - 1
+		if last < 0 || months[last].Year != t.Year() || months[last].Month != int(t.Month()) {
+			months = append(months, &archiveMonth{Year: t.Year(), Month: int(t.Month())})
+			last++
+		}
+		months[last].Count++
+		return true
+	})
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	writeResponse(w, r, months)
+}
+
+// getArchiveMonthHandler lists the articles created during a month,
+// the most recent first.
+func (s *server) getArchiveMonthHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	start, end, err := parseMonth(params["year"], params["month"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q, err := parseListQuery(r, "desc")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.checkListStatus(w, r, id, q) {
+		return
+	}
+	// The month narrows the since and until parameters.
+	if q.since.Before(start) {
+		q.since = start
+	}
+	if q.until.IsZero() || q.until.After(end) {
+		q.until = end
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	summary, err := parseView(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	articles, total, next, err := q.list(s.storeOf(r), id)
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	if summary {
+		articles = summarize(articles)
+	}
+	writeArticles(w, r, q, articles, total, next, fields)
+}
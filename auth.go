@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// authenticator checks the credentials of the requests.
+type authenticator struct {
+	// adminKey is accepted everywhere and required by the admin
+	// endpoints.
+	adminKey string
+	// keys holds the API keys, they can modify the articles of every
+	// user.
+	keys keyStore
+	// jwt checks the tokens of the users, a token only allows to modify
+	// the articles of its subject. The tokens are rejected when nil.
+	jwt *jwtVerifier
+	// sessions holds the sessions opened by /login.
+	sessions *sessionStore
+	// router finds the user of the requests.
+	router *mux.Router
+	// adminMetrics requires the admin key for /metrics, set when no
+	// admin listener serves it.
+	adminMetrics bool
+}
+
+// newAuthenticator returns the authenticator configured by cfg, nil
+// when no credential is configured. The API keys are only looked for
+// here, the keys created afterwards require a restart to turn the
+// authentication on.
+func newAuthenticator(cfg *config, store Store) (*authenticator, error) {
+	keys, ok := baseStore(store).(keyStore)
+	if cfg.AdminKey == "" && cfg.JWTKey == "" && cfg.JWKSURL == "" {
+		// An API key, e.g. created by the keys command, is a credential
+		// too: once one exists, the requests must carry a key.
+		if !ok {
+			return nil, nil
+		}
+		found, err := hasKeys(keys)
+		if err != nil || !found {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, errors.New("API keys not supported by the store")
+	}
+	a := &authenticator{
+		adminKey: cfg.AdminKey,
+		keys:     keys,
+		sessions: newSessionStore(),
+	}
+	if cfg.JWTKey != "" || cfg.JWKSURL != "" {
+		a.jwt = &jwtVerifier{
+			issuer:   cfg.JWTIssuer,
+			audience: cfg.JWTAudience,
+		}
+		if cfg.JWTKey != "" {
+			a.jwt.secret = []byte(cfg.JWTKey)
+		}
+		if cfg.JWKSURL != "" {
+			a.jwt.jwks = newJWKS(cfg.JWKSURL)
+		}
+	}
+	return a, nil
+}
+
+// identity is who sent a request.
+type identity struct {
+	admin bool
+	// user is the subject of a token or the user of an author key, the
+	// other API keys are not bound to a user.
+	user string
+	// keyHash is the hash of the API key, checked again by the sessions
+	// for the revoked keys to end them.
+	keyHash string
+	// expires is the expiration of the token, zero when none.
+	expires time.Time
+}
+
+// authError is a rejected credential, sent with status.
+type authError struct {
+	status int
+	msg    string
+}
+
+func (e *authError) Error() string {
+	return e.msg
+}
+
+// requestKey returns the credential sent with r, either as a bearer
+// token or in the X-API-Key header.
+func requestKey(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// identify returns the identity of the credential secret.
+func (a *authenticator) identify(secret string) (*identity, error) {
+	if a.adminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.adminKey)) == 1 {
+		return &identity{admin: true}, nil
+	}
+	if a.jwt != nil && isJWT(secret) {
+		claims, err := a.jwt.verify(secret, time.Now())
+		if err != nil {
+			return nil, &authError{http.StatusUnauthorized, err.Error()}
+		}
+		id := &identity{user: claims.Subject}
+		if claims.Expires != 0 {
+			id.expires = time.Unix(claims.Expires, 0)
+		}
+		return id, nil
+	}
+	return a.checkKey(hashKey(secret))
+}
+
+// checkKey returns the identity of the API key with the hash.
+func (a *authenticator) checkKey(hash string) (*identity, error) {
+	k, err := a.keys.KeyByHash(hash)
+	if err == errUnknownKey {
+		return nil, &authError{http.StatusUnauthorized, "invalid API key"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// An author key is bound to its user like a token.
+	return &identity{admin: k.Role == roleAdmin, user: k.User, keyHash: hash}, nil
+}
+
+// authResultKey is the context key of the authResult of a request.
+type authResultKey struct{}
+
+// authResult is the outcome of authenticate, kept in the context of the
+// request so that the credentials are checked once.
+type authResult struct {
+	who *identity
+	err error
+}
+
+// authenticate returns the identity of the credentials sent with r, nil
+// when there is none. The returned request carries the result for the
+// next calls.
+func (a *authenticator) authenticate(r *http.Request) (*http.Request, *identity, error) {
+	if res, ok := r.Context().Value(authResultKey{}).(*authResult); ok {
+		return r, res.who, res.err
+	}
+	res := &authResult{}
+	if secret := requestKey(r); secret != "" {
+		res.who, res.err = a.identify(secret)
+	} else if sess := a.sessions.get(r); sess != nil {
+		res.who, res.err = a.checkSession(r, sess)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), authResultKey{}, res))
+	return r, res.who, res.err
+}
+
+// adminPath reports whether path is an admin endpoint, requiring the
+// admin key.
+func adminPath(path string) bool {
+	return strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/debug/")
+}
+
+// adminOnly reports whether path requires the admin key: the admin
+// endpoints, and /metrics when adminMetrics is set.
+func (a *authenticator) adminOnly(path string) bool {
+	return adminPath(path) || (a.adminMetrics && path == "/metrics")
+}
+
+// authorize checks that who can send r.
+func (a *authenticator) authorize(r *http.Request, who *identity) error {
+	if who.admin {
+		return nil
+	}
+	if a.adminOnly(r.URL.Path) {
+		return &authError{http.StatusForbidden, "admin key required"}
+	}
+	var match mux.RouteMatch
+	if who.user != "" && a.router.Match(r, &match) && match.Vars["id"] != who.user {
+		if who.keyHash != "" {
+			return &authError{http.StatusForbidden, "key of another user"}
+		}
+		return &authError{http.StatusForbidden, "token of another user"}
+	}
+	return nil
+}
+
+// noAuthMiddleware rejects the requests to the admin endpoints, served
+// when the authentication is off, since nobody can be checked as the
+// admin.
+func noAuthMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if adminPath(r.URL.Path) {
+			writeError(w, http.StatusForbidden, "authentication is off, set an admin key")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authMiddleware requires credentials for the requests which could
+// modify data and for the trash: the admin key, an API key, the token of
+// the user whose articles are modified or the session of one of them.
+// The admin endpoints require the admin key, as does /metrics on the
+// public listener.
+func authMiddleware(h http.Handler, a *authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		private := a.adminOnly(r.URL.Path) || trashPath(r.URL.Path)
+		switch {
+		case r.URL.Path == "/login" || r.URL.Path == "/logout":
+			// The login handlers check their own credentials.
+			h.ServeHTTP(w, r)
+			return
+		case reactionPath(r.URL.Path):
+			// The readers react without credentials, once per client.
+			h.ServeHTTP(w, r)
+			return
+		case r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS":
+			if !private {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		r, who, err := a.authenticate(r)
+		if who == nil && err == nil {
+			err = &authError{http.StatusUnauthorized, "missing API key"}
+		}
+		if err == nil {
+			err = a.authorize(r, who)
+		}
+		if e, ok := err.(*authError); ok {
+			writeAuthError(w, r, e)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+			writeError(w, http.StatusInternalServerError, "fail to access DB")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// writeAuthError rejects r with the authError e.
+func writeAuthError(w http.ResponseWriter, r *http.Request, e *authError) {
+	slog.DebugContext(r.Context(), "request rejected", "status", e.status, "reason", e.msg)
+	if e.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="blog-api"`)
+	}
+	writeError(w, e.status, e.msg)
+}
+
+// checkSession returns the identity of the session sess. The requests
+// modifying data with a session must carry its CSRF token, since the
+// browsers send the cookie with the requests of any site.
+func (a *authenticator) checkSession(r *http.Request, sess *session) (*identity, error) {
+	safe := r.Method == "GET" || r.Method == "HEAD"
+	if !safe && !sess.checkCSRF(r.Header.Get(csrfHeader)) {
+		return nil, &authError{http.StatusForbidden, "invalid CSRF token"}
+	}
+	if sess.keyHash != "" {
+		return a.checkKey(sess.keyHash)
+	}
+	return &sess.identity, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupPrefix = "blog-"
+	backupSuffix = ".db"
+)
+
+// backupScheduler periodically writes snapshots of a store in a
+// directory and prunes the oldest ones.
+type backupScheduler struct {
+	store    backuper
+	dir      string
+	schedule *cronSchedule
+	keep     int
+}
+
+func backupName(t time.Time) string {
+	return backupPrefix + t.UTC().Format("20060102T150405Z") + backupSuffix
+}
+
+// run writes a backup each time the schedule fires, until stop is
+// closed.
+func (b *backupScheduler) run(stop <-chan struct{}) {
+	for {
+		next := b.schedule.next(time.Now())
+		if next.IsZero() {
+			slog.Warn("backup schedule never fires")
+			return
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Until(next)):
+		}
+		path, err := b.backup()
+		if err != nil {
+			slog.Error("fail to backup DB", "err", err)
+			continue
+		}
+		slog.Info("backup written", "path", path)
+		err = b.prune()
+		if err != nil {
+			slog.Error("fail to prune backups", "err", err)
+		}
+	}
+}
+
+// backup writes a snapshot in the backup directory. The snapshot is
+// written to a temporary file first so that a partial backup is never
+// mistaken for a complete one.
+func (b *backupScheduler) backup() (string, error) {
+	err := os.MkdirAll(b.dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(b.dir, backupName(time.Now()))
+	f, err := ioutil.TempFile(b.dir, ".backup-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = b.store.Backup(f)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	err = f.Close()
+	if err != nil {
+		return "", err
+	}
+	return path, os.Rename(f.Name(), path)
+}
+
+// prune removes the oldest backups, keeping the last b.keep ones.
+func (b *backupScheduler) prune() error {
+	if b.keep <= 0 {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Mode().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) <= b.keep {
+		return nil
+	}
+	// The timestamp in the name sorts chronologically.
+	sort.Strings(names)
+	for _, name := range names[:len(names)-b.keep] {
+		err = os.Remove(filepath.Join(b.dir, name))
+		if err != nil {
+			return fmt.Errorf("remove %s: %v", name, err)
+		}
+	}
+	return nil
+}
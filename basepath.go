@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// basePathKey is the context key of the base path of a request.
+type basePathKey struct{}
+
+// basePathOf returns the path the API of the request of ctx is mounted
+// under, empty at the root.
+func basePathOf(ctx context.Context) string {
+	p, _ := ctx.Value(basePathKey{}).(string)
+	return p
+}
+
+// linkPath returns path, absolute from the root of the API, as linked
+// from the responses to r.
+func linkPath(r *http.Request, path string) string {
+	return basePathOf(r.Context()) + path
+}
+
+// parseBasePath returns the base path p without its trailing slash, it
+// must be absolute.
+func parseBasePath(p string) (string, error) {
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	u, err := url.Parse(p)
+	if err != nil || u.Path != p || p[0] != '/' {
+		return "", fmt.Errorf("invalid path %q", p)
+	}
+	return p, nil
+}
+
+// basePathMiddleware serves the API under prefix, the other paths are
+// not found. The handlers see the paths without the prefix, the links
+// they send add it back with linkPath.
+func basePathMiddleware(h http.Handler, prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		if len(path) == len(r.URL.Path) || (path != "" && path[0] != '/') {
+			writeError(w, http.StatusNotFound, "nothing here...")
+			return
+		}
+		if path == "" {
+			path = "/"
+		}
+		r2 := r.WithContext(context.WithValue(r.Context(), basePathKey{}, prefix))
+		u := *r.URL
+		u.Path = path
+		u.RawPath = ""
+		if rawPath := strings.TrimPrefix(r.URL.RawPath, prefix); rawPath != r.URL.RawPath {
+			u.RawPath = rawPath
+		}
+		r2.URL = &u
+		h.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// batchStatus reports the outcome of an article of a batch.
+type batchStatus struct {
+	Title  string `json:"title"`
+	UUID   string `json:"uuid,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *server) batchArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	var articles []*article
+	err := decodeRequest(r, &articles)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// All the articles are validated before any is stored, the batch is
+	// stored in a single transaction only when all of them are valid.
+	now := time.Now()
+	statuses := make([]*batchStatus, len(articles))
+	titles := make(map[string]bool)
+	var valid []*article
+	for i, a := range articles {
+		if a == nil {
+			a = &article{}
+		}
+		statuses[i] = &batchStatus{Title: a.Title, Status: http.StatusOK}
+		err := prepareArticle(a, now)
+		if err == nil && titles[a.Title] {
+			err = errors.New("duplicate title in batch")
+		}
+		if err == nil {
+			err = s.checkCategory(id, a.Category)
+			if _, ok := err.(invalidError); err != nil && !ok {
+				slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+				writeError(w, http.StatusInternalServerError, "fail to access DB")
+				return
+			}
+		}
+		if _, ok := err.(validationError); ok {
+			statuses[i].Status = http.StatusUnprocessableEntity
+			statuses[i].Error = err.Error()
+			continue
+		}
+		if err != nil {
+			statuses[i].Status = http.StatusBadRequest
+			statuses[i].Error = err.Error()
+			continue
+		}
+		titles[a.Title] = true
+		valid = append(valid, a)
+	}
+	if len(valid) < len(articles) {
+		for _, st := range statuses {
+			if st.Status == http.StatusOK {
+				st.Status = http.StatusFailedDependency
+				st.Error = "not stored, the batch has invalid articles"
+			}
+		}
+		writeBatchStatuses(w, r, http.StatusUnprocessableEntity, statuses)
+		return
+	}
+
+	err = s.storeOf(r).PutAll(id, valid)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	for i, st := range statuses {
+		st.UUID = valid[i].UUID
+	}
+	writeResponse(w, r, statuses)
+}
+
+// writeBatchStatuses writes statuses with the status code, encoded
+// with the codec negotiated for r.
+func writeBatchStatuses(w http.ResponseWriter, r *http.Request, code int, statuses []*batchStatus) {
+	mediaType := responseType(w, r, codecTypes)
+	if mediaType == "" {
+		return
+	}
+	data, err := codecOf(mediaType).marshal(statuses)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "encoding fail", "err", err)
+		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(code)
+	w.Write(data)
+}
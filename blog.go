@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// blogPageSize is the number of articles of a page of the blog.
+const blogPageSize = 10
+
+// blogTemplates render the read-only blog of an user. They are part of
+// the source for the binary to be self-contained. The links are
+// relative, the pages are all in /blog/{id}/ unless exported to a static
+// site.
+var blogTemplates = template.Must(template.New("blog").Funcs(template.FuncMap{
+	"path":    url.PathEscape,
+	"summary": summaryOf,
+	"date": func(t time.Time) string {
+		return t.Format("January 2, 2006")
+	},
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html{{with .Lang}} lang="{{.}}"{{end}}>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="alternate" type="application/rss+xml" title="Articles of {{.ID}}" href="{{.Base}}../../feed/{{path .ID}}/rss.xml">
+<link rel="alternate" type="application/atom+xml" title="Articles of {{.ID}}" href="{{.Base}}../../feed/{{path .ID}}/atom.xml">
+<style>
+body { max-width: 42em; margin: 2em auto; padding: 0 1em; font-family: Georgia, serif; line-height: 1.6; color: #222; }
+a { color: #0645ad; }
+header a { color: inherit; text-decoration: none; }
+.meta { color: #666; font-size: 0.9em; }
+.tags a { margin-right: 0.5em; }
+nav { display: flex; justify-content: space-between; margin: 2em 0; }
+pre { overflow-x: auto; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+<header><h1><a href="{{.Home}}">Articles of {{.ID}}</a></h1></header>
+{{end}}
+
+{{define "footer"}}<footer class="meta"><a href="{{.Base}}../../feed/{{path .ID}}/rss.xml">RSS</a> · <a href="{{.Base}}../../feed/{{path .ID}}/atom.xml">Atom</a></footer>
+</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "header" .}}
+<main>
+{{with .Tag}}<p>Articles tagged <strong>{{.}}</strong>, <a href="{{$.Home}}">show all</a>.</p>{{end}}
+{{range .Articles}}<article>
+<h2><a href="{{$.ArticleLink .}}">{{.Title}}</a></h2>
+<p class="meta"><time datetime="{{.Timestamp.Format "2006-01-02T15:04:05Z07:00"}}">{{date .Timestamp}}</time></p>
+<p>{{summary .}}</p>
+</article>
+{{else}}<p>No articles yet.</p>
+{{end}}</main>
+<nav>
+<span>{{if .Prev}}<a href="{{.PageLink .Prev}}">Newer articles</a>{{end}}</span>
+<span>{{if .Next}}<a href="{{.PageLink .Next}}">Older articles</a>{{end}}</span>
+</nav>
+{{template "footer" .}}{{end}}
+
+{{define "article"}}{{template "header" .}}
+<main>
+<article>
+<h2>{{.Article.Title}}</h2>
+<p class="meta"><time datetime="{{.Article.Timestamp.Format "2006-01-02T15:04:05Z07:00"}}">{{date .Article.Timestamp}}</time>
+{{with .Article.Updated}}· updated <time datetime="{{.Format "2006-01-02T15:04:05Z07:00"}}">{{date .}}</time>{{end}}</p>
+{{.Content}}
+{{with .Article.Tags}}<p class="meta tags">{{range .}}<a href="{{$.TagLink .}}">#{{.}}</a>{{end}}</p>{{end}}
+</article>
+</main>
+<nav><a href="{{.Home}}">All articles</a></nav>
+{{template "footer" .}}{{end}}
+`))
+
+// blogPage holds the data of the blog templates.
+type blogPage struct {
+	// ID is the user of the blog.
+	ID    string
+	Title string
+	Lang  string
+
+	// Articles, Tag, Prev and Next are set on the index, Prev and Next
+	// are the numbers of the adjacent pages, zero when there is none.
+	Articles []*article
+	Tag      string
+	Prev     int
+	Next     int
+
+	// Article and Content are set on the page of an article.
+	Article *article
+	Content template.HTML
+
+	// Base is the relative path from the page to the blog of the user,
+	// the pages of a static site are not all in the same directory.
+	Base string
+	// tagFiles holds the names of the directories of the tags of a
+	// static site, nil when the blog is served.
+	tagFiles map[string]string
+}
+
+// Home returns the link from the page to the index of the blog.
+func (p *blogPage) Home() string {
+	if p.Base == "" {
+		return "./"
+	}
+	return p.Base
+}
+
+// ArticleLink returns the link from the page to the article a.
+func (p *blogPage) ArticleLink(a *article) string {
+	if p.tagFiles == nil {
+		return url.PathEscape(a.Slug)
+	}
+	return p.Base + url.PathEscape(a.Slug) + "/"
+}
+
+// PageLink returns the link from the page to the page n of the index,
+// restricted to the tag of the page.
+func (p *blogPage) PageLink(n int) string {
+	if p.tagFiles == nil {
+		link := "?page=" + strconv.Itoa(n)
+		if p.Tag != "" {
+			link += "&tag=" + url.QueryEscape(p.Tag)
+		}
+		return link
+	}
+	switch {
+	case p.Tag != "" && n == 1:
+		return p.Base + "tag/" + p.tagFiles[p.Tag] + "/"
+	case p.Tag != "":
+		return p.Base + "tag/" + p.tagFiles[p.Tag] + "/" + strconv.Itoa(n) + ".html"
+	case n == 1:
+		return p.Home()
+	}
+	return p.Base + "page/" + strconv.Itoa(n) + ".html"
+}
+
+// TagLink returns the link from the page to the index of the articles
+// tagged tag.
+func (p *blogPage) TagLink(tag string) string {
+	if p.tagFiles == nil {
+		return "./?tag=" + url.QueryEscape(tag)
+	}
+	return p.Base + "tag/" + p.tagFiles[tag] + "/"
+}
+
+// writeBlogPage renders the template name with page.
+func writeBlogPage(w http.ResponseWriter, name string, page *blogPage) {
+	buf := &bytes.Buffer{}
+	err := blogTemplates.ExecuteTemplate(buf, name, page)
+	if err != nil {
+		slog.Error("rendering fail", "err", err)
+		writeError(w, http.StatusInternalServerError, "rendering fail")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// parsePage reads the page query parameter, pages start at 1.
+func parsePage(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("page")
+	if v == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, errors.New("invalid page parameter")
+	}
+	return n, nil
+}
+
+// getBlogHandler renders the index of the blog of an user, the latest
+// published articles first.
+func (s *server) getBlogHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	n, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q := &listQuery{
+		order:  byTimeDesc,
+		offset: (n - 1) * blogPageSize,
+		limit:  blogPageSize,
+		now:    time.Now(),
+		tag:    strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag"))),
+		status: statusPublished,
+	}
+	articles, total, _, err := q.list(s.storeOf(r), id)
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	if len(articles) == 0 && n > 1 {
+		writeError(w, http.StatusNotFound, "unknown page")
+		return
+	}
+
+	page := &blogPage{
+		ID:       id,
+		Title:    "Articles of " + id,
+		Articles: articles,
+		Tag:      q.tag,
+		Prev:     n - 1,
+	}
+	if q.offset+len(articles) < total {
+		page.Next = n + 1
+	}
+	writeBlogPage(w, "index", page)
+}
+
+// getBlogArticleHandler renders a published article of the blog of an
+// user, found by its slug.
+func (s *server) getBlogArticleHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	slug, ok := params["slug"]
+	if !ok || slug == "" {
+		writeError(w, http.StatusBadRequest, "missing slug")
+		return
+	}
+
+	a, err := s.storeOf(r).GetBySlug(id, slug)
+	if err == nil && (a.expired(time.Now()) || !a.published(time.Now())) {
+		err = errUnknownSlug
+	}
+	if err == errUnknownID || err == errUnknownSlug {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	variant, err := localize(w, r, a)
+	if err == errUnknownLanguage {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.views.add(r, id, a.UUID)
+
+	writeBlogPage(w, "article", &blogPage{
+		ID:      id,
+		Title:   variant.Title,
+		Lang:    variant.Language,
+		Article: variant,
+		Content: template.HTML(renderMarkdown(variant.Content)),
+	})
+}
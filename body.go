@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// bodyLimitMiddleware rejects the request bodies larger than max bytes
+// with 413, before they reach the handlers. The bodies are read up to
+// the limit, the imports and the restores are streamed and not limited.
+func bodyLimitMiddleware(h http.Handler, max int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody || r.URL.Path == "/admin/import" || r.URL.Path == "/admin/restore" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		tooLarge := fmt.Sprintf("request body larger than %d bytes", max)
+		if r.ContentLength > max {
+			writeError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		// The length is unknown or may be wrong, the reader stops after
+		// the limit and closes the connection.
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, max))
+		if err != nil && int64(len(data)) >= max {
+			writeError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "fail to read body")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,1112 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// recordVersion is the current version of the article records
+// written to Bolt.
+const recordVersion = 1
+
+var errUnknownVersion = errors.New("unknown record version")
+
+// record is the representation of an article stored in Bolt.
+type record struct {
+	Version int      `json:"version"`
+	Article *article `json:"article"`
+}
+
+func encodeRecord(a *article) ([]byte, error) {
+	// The reactions and the views are counted apart from the records.
+	if a.Reactions != nil || a.Views != 0 {
+		c := *a
+		c.Reactions = nil
+		c.Views = 0
+		a = &c
+	}
+	return json.Marshal(&record{Version: recordVersion, Article: a})
+}
+
+func decodeRecord(data []byte) (*article, error) {
+	r := &record{}
+	err := json.Unmarshal(data, r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Version != recordVersion || r.Article == nil {
+		return nil, errUnknownVersion
+	}
+	return r.Article, nil
+}
+
+// marshal encodes a as a record, encrypting it when a key is set.
+func (s *boltStore) marshal(a *article) ([]byte, error) {
+	data, err := encodeRecord(a)
+	if err != nil || s.aead == nil {
+		return data, err
+	}
+	return seal(s.aead, data)
+}
+
+// unmarshal decodes a record, plain text records are accepted even
+// when a key is set.
+func (s *boltStore) unmarshal(data []byte) (*article, error) {
+	if isSealed(data) {
+		var err error
+		data, err = unseal(s.aead, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return decodeRecord(data)
+}
+
+// articlesBucket contains a bucket per user ID, each one containing
+// the user articles keyed by UUID.
+var articlesBucket = []byte("_articles")
+
+// titleIndexBucket contains a bucket per user ID mapping the user
+// article titles to their UUID.
+var titleIndexBucket = []byte("_title_index")
+
+// timeIndexBucket contains a bucket per user ID indexing the user
+// articles by timestamp, the keys are timeKey and the values empty.
+var timeIndexBucket = []byte("_time_index")
+
+// slugIndexBucket contains a bucket per user ID mapping the user
+// article slugs to their UUID.
+var slugIndexBucket = []byte("_slug_index")
+
+// tagIndexBucket contains a bucket per user ID indexing the user
+// articles by tag, the keys are tagKey and the values empty.
+var tagIndexBucket = []byte("_tag_index")
+
+// categoriesBucket contains a bucket per user ID, each one containing
+// the user categories keyed by path.
+var categoriesBucket = []byte("_categories")
+
+// dueIndexBucket contains a bucket per user ID indexing the user
+// articles by the times the background jobs act on them: their
+// expiration and the publication time of the drafts.
+var dueIndexBucket = []byte("_due_index")
+
+// trashBucket contains a bucket per user ID, each one containing the
+// user deleted articles keyed by UUID, an article can be deleted again
+// once recreated.
+var trashBucket = []byte("_trash")
+
+// boltStore is a Store backed by a Bolt database.
+type boltStore struct {
+	path string
+	// mu is held for writing while the database is swapped.
+	mu sync.RWMutex
+	db *bolt.DB
+	// aead encrypts the records when set.
+	aead     cipher.AEAD
+	readOnly bool
+}
+
+// lockTimeout is how long opening a database waits for the process
+// holding it, e.g. a command run while the server is.
+const lockTimeout = 5 * time.Second
+
+// newBoltStore opens the database at path, applying the missing
+// migrations. The records are encrypted with aead unless it is nil.
+// A read-only database is not migrated, it must be up to date.
+func newBoltStore(path string, aead cipher.AEAD, readOnly bool) (*boltStore, error) {
+	db, err := bolt.Open(path, 0666, &bolt.Options{ReadOnly: readOnly, Timeout: lockTimeout})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("%s is in use by another process", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &boltStore{path: path, db: db, aead: aead, readOnly: readOnly}
+	if readOnly {
+		err = s.checkSchema()
+	} else {
+		err = s.migrate()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *boltStore) view(fn func(tx *bolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(fn)
+}
+
+func (s *boltStore) update(fn func(tx *bolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(fn)
+}
+
+// userBuckets holds the buckets of a user.
+type userBuckets struct {
+	articles *bolt.Bucket
+	titles   *bolt.Bucket
+	times    *bolt.Bucket
+	slugs    *bolt.Bucket
+	tags     *bolt.Bucket
+	due      *bolt.Bucket
+	// reactions is nil until an article of the user has reactions.
+	reactions *bolt.Bucket
+}
+
+// buckets returns the buckets of user id, or errUnknownID.
+func buckets(tx *bolt.Tx, id []byte) (*userBuckets, error) {
+	b := &userBuckets{
+		articles:  tx.Bucket(articlesBucket).Bucket(id),
+		titles:    tx.Bucket(titleIndexBucket).Bucket(id),
+		times:     tx.Bucket(timeIndexBucket).Bucket(id),
+		slugs:     tx.Bucket(slugIndexBucket).Bucket(id),
+		tags:      tx.Bucket(tagIndexBucket).Bucket(id),
+		due:       tx.Bucket(dueIndexBucket).Bucket(id),
+		reactions: reactionsOf(tx, id),
+	}
+	if b.articles == nil || b.titles == nil || b.times == nil || b.slugs == nil || b.tags == nil || b.due == nil {
+		return nil, errUnknownID
+	}
+	return b, nil
+}
+
+// createBuckets returns the buckets of user id, creating them if
+// needed.
+func createBuckets(tx *bolt.Tx, id []byte) (*userBuckets, error) {
+	var err error
+	b := &userBuckets{}
+	b.articles, err = tx.Bucket(articlesBucket).CreateBucketIfNotExists(id)
+	if err != nil {
+		return nil, err
+	}
+	b.titles, err = tx.Bucket(titleIndexBucket).CreateBucketIfNotExists(id)
+	if err != nil {
+		return nil, err
+	}
+	b.times, err = tx.Bucket(timeIndexBucket).CreateBucketIfNotExists(id)
+	if err != nil {
+		return nil, err
+	}
+	b.slugs, err = tx.Bucket(slugIndexBucket).CreateBucketIfNotExists(id)
+	if err != nil {
+		return nil, err
+	}
+	b.tags, err = tx.Bucket(tagIndexBucket).CreateBucketIfNotExists(id)
+	if err != nil {
+		return nil, err
+	}
+	b.due, err = tx.Bucket(dueIndexBucket).CreateBucketIfNotExists(id)
+	if err != nil {
+		return nil, err
+	}
+	b.reactions = reactionsOf(tx, id)
+	return b, nil
+}
+
+// get returns the article stored under uuid with its reactions.
+func (s *boltStore) get(b *userBuckets, uuid []byte) (*article, error) {
+	data := b.articles.Get(uuid)
+	if data == nil {
+		return nil, errUnknownUUID
+	}
+	a, err := s.unmarshal(data)
+	if err != nil || b.reactions == nil {
+		return a, err
+	}
+	a.Reactions, err = decodeReactions(b.reactions.Get(uuid))
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// getByTitle returns the article title.
+func (s *boltStore) getByTitle(b *userBuckets, title []byte) (*article, error) {
+	uuid := b.titles.Get(title)
+	if uuid == nil {
+		return nil, errUnknownTitle
+	}
+	return s.get(b, uuid)
+}
+
+func (s *boltStore) Get(id, title string) (*article, error) {
+	var a *article
+	err := s.view(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		a, err = s.getByTitle(b, []byte(title))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *boltStore) GetBySlug(id, slug string) (*article, error) {
+	var a *article
+	err := s.view(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		uuid := b.slugs.Get([]byte(slug))
+		if uuid == nil {
+			return errUnknownSlug
+		}
+		a, err = s.get(b, uuid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *boltStore) GetByUUID(id, uuid string) (*article, error) {
+	var a *article
+	err := s.view(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		a, err = s.get(b, []byte(uuid))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *boltStore) Put(id string, a *article) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return s.putArticle(tx, []byte(id), a)
+	})
+}
+
+func (s *boltStore) PutAll(id string, articles []*article) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, a := range articles {
+			err := s.putArticle(tx, []byte(id), a)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Update(id, title string, fn func(a *article) error) (*article, error) {
+	var a *article
+	err := s.update(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		a, err = s.getByTitle(b, []byte(title))
+		if err != nil {
+			return err
+		}
+		uuid, slug := a.UUID, a.Slug
+		err = fn(a)
+		if err != nil {
+			return err
+		}
+		a.UUID, a.Slug = uuid, slug
+		if a.Title != title && b.titles.Get([]byte(a.Title)) != nil {
+			return errTitleExists
+		}
+		return s.putArticle(tx, []byte(id), a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// putArticle stores a for user id and updates the indexes. The article
+// replaces the one with the same title, keeping its UUID and slug.
+// Otherwise a new UUID is assigned unless a already has one, and a new
+// slug unless a has one which is free. All the writes of articles go
+// through putArticle and deleteArticle.
+func (s *boltStore) putArticle(tx *bolt.Tx, id []byte, a *article) error {
+	b, err := createBuckets(tx, id)
+	if err != nil {
+		return err
+	}
+	if uuid := b.titles.Get([]byte(a.Title)); uuid != nil {
+		a.UUID = string(uuid)
+	} else if a.UUID == "" {
+		a.UUID = newUUID()
+	}
+	a.Reactions = nil
+	if old, err := s.get(b, []byte(a.UUID)); err == nil {
+		a.Reactions = old.Reactions
+		if a.Slug == "" {
+			a.Slug = old.Slug
+		}
+		err = s.unindex(b, old)
+		if err != nil {
+			return err
+		}
+	} else if err != errUnknownUUID {
+		return err
+	}
+	if a.Slug == "" || b.slugs.Get([]byte(a.Slug)) != nil {
+		a.Slug = uniqueSlug(a.Title, func(slug string) bool {
+			return b.slugs.Get([]byte(slug)) != nil
+		})
+	}
+	data, err := s.marshal(a)
+	if err != nil {
+		return err
+	}
+	err = b.articles.Put([]byte(a.UUID), data)
+	if err != nil {
+		return err
+	}
+	err = b.titles.Put([]byte(a.Title), []byte(a.UUID))
+	if err != nil {
+		return err
+	}
+	err = b.slugs.Put([]byte(a.Slug), []byte(a.UUID))
+	if err != nil {
+		return err
+	}
+	for _, t := range a.Tags {
+		err = b.tags.Put(tagKey(t, a.UUID), nil)
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range dueTimes(a) {
+		err = b.due.Put(timeKey(t, a.UUID), nil)
+		if err != nil {
+			return err
+		}
+	}
+	return b.times.Put(timeKey(a.Timestamp, a.UUID), nil)
+}
+
+// dueTimes returns the times the background jobs act on a: when it
+// expires and, for a draft, when it is published.
+func dueTimes(a *article) []time.Time {
+	var times []time.Time
+	if a.ExpiresAt != nil {
+		times = append(times, *a.ExpiresAt)
+	}
+	if a.Status == statusDraft && a.PublishAt != nil {
+		times = append(times, *a.PublishAt)
+	}
+	return times
+}
+
+// unindex removes the index entries of a.
+func (s *boltStore) unindex(b *userBuckets, a *article) error {
+	err := b.titles.Delete([]byte(a.Title))
+	if err != nil {
+		return err
+	}
+	if a.Slug != "" {
+		err = b.slugs.Delete([]byte(a.Slug))
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range a.Tags {
+		err = b.tags.Delete(tagKey(t, a.UUID))
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range dueTimes(a) {
+		err = b.due.Delete(timeKey(t, a.UUID))
+		if err != nil {
+			return err
+		}
+	}
+	return b.times.Delete(timeKey(a.Timestamp, a.UUID))
+}
+
+// deleteArticle removes the article a and its index entries.
+func (s *boltStore) deleteArticle(b *userBuckets, a *article) error {
+	err := s.unindex(b, a)
+	if err != nil {
+		return err
+	}
+	return b.articles.Delete([]byte(a.UUID))
+}
+
+func (s *boltStore) Delete(id, title string, check func(a *article) error) error {
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		a, err := s.getByTitle(b, []byte(title))
+		if err != nil {
+			return err
+		}
+		if check != nil {
+			err = check(a)
+			if err != nil {
+				return err
+			}
+		}
+		return s.moveToTrash(tx, b, []byte(id), a, time.Now())
+	})
+}
+
+// moveToTrash moves the article a of user id to the trash.
+func (s *boltStore) moveToTrash(tx *bolt.Tx, b *userBuckets, id []byte, a *article, now time.Time) error {
+	err := s.deleteArticle(b, a)
+	if err != nil {
+		return err
+	}
+	a.Deleted = &now
+	data, err := s.marshal(a)
+	if err != nil {
+		return err
+	}
+	t, err := tx.Bucket(trashBucket).CreateBucketIfNotExists(id)
+	if err != nil {
+		return err
+	}
+	return t.Put([]byte(a.UUID), data)
+}
+
+func (s *boltStore) List(id string) ([]*article, error) {
+	var articles []*article
+	err := s.Walk(id, byTitle, nil, func(a *article) bool {
+		articles = append(articles, a)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return articles, nil
+}
+
+func (s *boltStore) Walk(id string, o order, after []byte, fn func(a *article) bool) error {
+	return s.view(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		// The keys of the indexes are the walk keys.
+		c := b.titles.Cursor()
+		if o != byTitle {
+			c = b.times.Cursor()
+		}
+		first, next := c.First, c.Next
+		if o == byTimeDesc {
+			first, next = c.Last, c.Prev
+		}
+		if after != nil {
+			first = func() ([]byte, []byte) {
+				k, v := c.Seek(after)
+				switch {
+				case k == nil && o == byTimeDesc:
+					return c.Last()
+				case k == nil:
+					return nil, nil
+				case o == byTimeDesc:
+					return c.Prev()
+				case bytes.Equal(k, after):
+					return c.Next()
+				}
+				return k, v
+			}
+		}
+		for k, v := first(); k != nil; k, v = next() {
+			uuid := v
+			if o != byTitle {
+				uuid = k[timeKeyLen:]
+			}
+			a, err := s.get(b, uuid)
+			if err != nil {
+				return err
+			}
+			if !fn(a) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Users() ([]string, error) {
+	var users []string
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(articlesBucket).ForEach(func(k, v []byte) error {
+			users = append(users, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *boltStore) DeleteAll(id string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		var articles []*article
+		err = b.articles.ForEach(func(k, v []byte) error {
+			a, err := s.unmarshal(v)
+			if err != nil {
+				return err
+			}
+			articles = append(articles, a)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, a := range articles {
+			err = s.moveToTrash(tx, b, []byte(id), a, now)
+			if err != nil {
+				return err
+			}
+		}
+		for _, root := range [][]byte{articlesBucket, titleIndexBucket, timeIndexBucket, slugIndexBucket, tagIndexBucket, dueIndexBucket} {
+			err = tx.Bucket(root).DeleteBucket([]byte(id))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) DeleteFunc(id string, match func(a *article) bool) (int, error) {
+	n := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		var articles []*article
+		err = b.articles.ForEach(func(k, v []byte) error {
+			a, err := s.unmarshal(v)
+			if err != nil {
+				return err
+			}
+			if match(a) {
+				articles = append(articles, a)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, a := range articles {
+			err = s.moveToTrash(tx, b, []byte(id), a, now)
+			if err != nil {
+				return err
+			}
+		}
+		n = len(articles)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Tags counts the entries of the tag index.
+func (s *boltStore) Tags(id string) (map[string]int, error) {
+	tags := make(map[string]int)
+	err := s.view(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		return b.tags.ForEach(func(k, _ []byte) error {
+			tags[string(k[:bytes.IndexByte(k, 0)])]++
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Stats reads the size of the records and the time index, the articles
+// are not decoded.
+func (s *boltStore) Stats(id string) (*articleStats, error) {
+	stats := &articleStats{}
+	err := s.view(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		err = b.articles.ForEach(func(k, v []byte) error {
+			stats.Count++
+			stats.Bytes += int64(len(v))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		c := b.times.Cursor()
+		if k, _ := c.First(); k != nil {
+			oldest := keyTime(k)
+			stats.Oldest = &oldest
+		}
+		if k, _ := c.Last(); k != nil {
+			newest := keyTime(k)
+			stats.Newest = &newest
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (s *boltStore) Trash(id string) ([]*article, error) {
+	var articles []*article
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(trashBucket).Bucket([]byte(id))
+		if b == nil {
+			return errUnknownID
+		}
+		return b.ForEach(func(k, v []byte) error {
+			a, err := s.unmarshal(v)
+			if err != nil {
+				return err
+			}
+			articles = append(articles, a)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTrash(articles)
+	return articles, nil
+}
+
+func (s *boltStore) Restore(id, uuid string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		t := tx.Bucket(trashBucket).Bucket([]byte(id))
+		if t == nil {
+			return errUnknownID
+		}
+		data := t.Get([]byte(uuid))
+		if data == nil {
+			return errUnknownUUID
+		}
+		a, err := s.unmarshal(data)
+		if err != nil {
+			return err
+		}
+		if b, err := buckets(tx, []byte(id)); err == nil && b.titles.Get([]byte(a.Title)) != nil {
+			return errTitleExists
+		}
+		a.Deleted = nil
+		err = s.putArticle(tx, []byte(id), a)
+		if err != nil {
+			return err
+		}
+		return t.Delete([]byte(uuid))
+	})
+}
+
+func (s *boltStore) Purge(before time.Time) (int, error) {
+	n := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(trashBucket).ForEach(func(id, _ []byte) error {
+			t := tx.Bucket(trashBucket).Bucket(id)
+			var expired, uuids [][]byte
+			err := t.ForEach(func(k, v []byte) error {
+				a, err := s.unmarshal(v)
+				if err != nil {
+					return err
+				}
+				if a.Deleted != nil && a.Deleted.Before(before) {
+					expired = append(expired, append([]byte(nil), k...))
+					uuids = append(uuids, []byte(a.UUID))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			purged := make(map[string]bool, len(expired))
+			for i, k := range expired {
+				err = t.Delete(k)
+				if err == nil {
+					err = deleteReactions(tx, id, string(uuids[i]))
+				}
+				if err != nil {
+					return err
+				}
+				purged[string(uuids[i])] = true
+			}
+			err = deleteViews(tx, id, purged)
+			if err != nil {
+				return err
+			}
+			n += len(expired)
+			return nil
+		})
+	})
+	return n, err
+}
+
+func (s *boltStore) Category(id, path string) (*category, error) {
+	c := &category{}
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(categoriesBucket).Bucket([]byte(id))
+		if b == nil {
+			return errUnknownCategory
+		}
+		data := b.Get([]byte(path))
+		if data == nil {
+			return errUnknownCategory
+		}
+		return json.Unmarshal(data, c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *boltStore) Categories(id string) ([]*category, error) {
+	var categories []*category
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(categoriesBucket).Bucket([]byte(id))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			c := &category{}
+			err := json.Unmarshal(v, c)
+			if err != nil {
+				return err
+			}
+			categories = append(categories, c)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (s *boltStore) PutCategory(id string, c *category) error {
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(categoriesBucket).CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return err
+		}
+		if parent := c.parent(); parent != "" && b.Get([]byte(parent)) == nil {
+			return errUnknownParent
+		}
+		data, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(c.Path), data)
+	})
+}
+
+func (s *boltStore) DeleteCategory(id, path string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(categoriesBucket).Bucket([]byte(id))
+		if b == nil || b.Get([]byte(path)) == nil {
+			return errUnknownCategory
+		}
+		prefix := []byte(path + "/")
+		if k, _ := b.Cursor().Seek(prefix); k != nil && bytes.HasPrefix(k, prefix) {
+			return errCategoryInUse
+		}
+		if ub, err := buckets(tx, []byte(id)); err == nil {
+			err = ub.articles.ForEach(func(k, v []byte) error {
+				a, err := s.unmarshal(v)
+				if err != nil {
+					return err
+				}
+				if a.Category == path {
+					return errCategoryInUse
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return b.Delete([]byte(path))
+	})
+}
+
+func (s *boltStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Close()
+}
+
+// Backup writes a consistent snapshot of the database to w, without
+// blocking the writers.
+func (s *boltStore) Backup(w io.Writer) (int64, error) {
+	var n int64
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// Check opens a read transaction and, unless the database is read-only,
+// writes a file next to it to make sure the disk accepts writes.
+func (s *boltStore) Check() map[string]error {
+	checks := map[string]error{
+		"db": s.view(func(tx *bolt.Tx) error {
+			if tx.Bucket(articlesBucket) == nil {
+				return errors.New("missing articles bucket")
+			}
+			return nil
+		}),
+	}
+	if !s.readOnly {
+		checks["disk"] = checkWritable(filepath.Dir(s.path))
+	}
+	return checks
+}
+
+// DBStats returns the statistics of the database and of its top-level
+// buckets.
+func (s *boltStore) DBStats() (*dbStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := &dbStats{Buckets: make(map[string]*bucketStats)}
+	// The stats are read before the transaction reading the buckets, not
+	// to count it.
+	st := s.db.Stats()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stats.PageSize = s.db.Info().PageSize
+		stats.Size = tx.Size()
+		stats.FreePages = st.FreePageN
+		stats.PendingPages = st.PendingPageN
+		stats.FreeAlloc = st.FreeAlloc
+		stats.FreelistInuse = st.FreelistInuse
+		stats.ReadTxs = st.TxN
+		stats.OpenReadTxs = st.OpenTxN
+		stats.Writes = st.TxStats.Write
+		stats.WriteSeconds = st.TxStats.WriteTime.Seconds()
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			bs := b.Stats()
+			stats.Buckets[string(name)] = &bucketStats{
+				Keys:          bs.KeyN,
+				Depth:         bs.Depth,
+				Buckets:       bs.BucketN,
+				BranchPages:   bs.BranchPageN,
+				LeafPages:     bs.LeafPageN,
+				OverflowPages: bs.BranchOverflowN + bs.LeafOverflowN,
+				LeafAlloc:     bs.LeafAlloc,
+				LeafInuse:     bs.LeafInuse,
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// checkWritable creates and removes a file in dir.
+func checkWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".blog-api-check-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write([]byte("ok"))
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// encryptAll encrypts the plain text records, it returns the number of
+// records encrypted.
+func (s *boltStore) encryptAll() (int, error) {
+	if s.aead == nil {
+		return 0, errors.New("no encryption key is set")
+	}
+	n := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{articlesBucket, trashBucket} {
+			root := tx.Bucket(name)
+			err := root.ForEach(func(id, _ []byte) error {
+				b := root.Bucket(id)
+				updates := make(map[string][]byte)
+				err := b.ForEach(func(k, v []byte) error {
+					if isSealed(v) {
+						return nil
+					}
+					data, err := seal(s.aead, v)
+					if err != nil {
+						return err
+					}
+					updates[string(k)] = data
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				for k, v := range updates {
+					err = b.Put([]byte(k), v)
+					if err != nil {
+						return err
+					}
+				}
+				n += len(updates)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Compact rewrites the database into a fresh file and swaps it with
+// the current one. It returns the size of the file before and after.
+// Requests are blocked during the compaction.
+func (s *boltStore) Compact() (before, after int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return 0, 0, err
+	}
+	before = fi.Size()
+
+	tmp := s.path + ".compact"
+	dst, err := bolt.Open(tmp, 0666, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = dst.Update(func(dtx *bolt.Tx) error {
+		return s.db.View(func(stx *bolt.Tx) error {
+			return stx.ForEach(func(name []byte, src *bolt.Bucket) error {
+				b, err := dtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(b, src)
+			})
+		})
+	})
+	if err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return 0, 0, err
+	}
+	err = dst.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return 0, 0, err
+	}
+
+	err = s.db.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return 0, 0, err
+	}
+	// The original file is set aside until the compacted one opens, it
+	// is put back and reopened when anything fails.
+	orig := s.path + ".orig"
+	err = os.Rename(s.path, orig)
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+		if err == nil {
+			err = s.reopen()
+		}
+		if err == nil {
+			os.Remove(orig)
+			fi, err = os.Stat(s.path)
+			if err != nil {
+				return 0, 0, err
+			}
+			return before, fi.Size(), nil
+		}
+		os.Rename(orig, s.path)
+	}
+	os.Remove(tmp)
+	openErr := s.reopen()
+	if openErr != nil {
+		return 0, 0, fmt.Errorf("%v, then fail to reopen the database: %v", err, openErr)
+	}
+	return 0, 0, err
+}
+
+// reopen opens the database file of s again, once s.db is closed.
+func (s *boltStore) reopen() error {
+	db, err := bolt.Open(s.path, 0666, &bolt.Options{ReadOnly: s.readOnly, Timeout: lockTimeout})
+	if err != nil {
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+// copyBucket copies the keys and the nested buckets of src into dst.
+func copyBucket(dst, src *bolt.Bucket) error {
+	// Keys are inserted in order, pages can be filled completely.
+	dst.FillPercent = 1.0
+	err := dst.SetSequence(src.Sequence())
+	if err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		b, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(b, src.Bucket(k))
+	})
+}
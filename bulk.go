@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// deleteFilter selects the articles removed by a bulk deletion.
+type deleteFilter struct {
+	// before selects the articles created before this time.
+	before time.Time
+	// tag selects the articles with this tag.
+	tag string
+}
+
+// parseDeleteFilter reads the bulk deletion filters from the URL query.
+// It returns nil when no filter is given, the whole user is then
+// deleted.
+func parseDeleteFilter(r *http.Request) (*deleteFilter, error) {
+	values := r.URL.Query()
+	_, hasBefore := values["before"]
+	_, hasTag := values["tag"]
+	if !hasBefore && !hasTag {
+		return nil, nil
+	}
+	// An empty filter is rejected instead of deleting everything.
+	f := &deleteFilter{}
+	if hasBefore {
+		t, err := time.Parse(time.RFC3339, values.Get("before"))
+		if err != nil {
+			return nil, errors.New("invalid before parameter")
+		}
+		f.before = t
+	}
+	if hasTag {
+		f.tag = strings.ToLower(strings.TrimSpace(values.Get("tag")))
+		if f.tag == "" {
+			return nil, errors.New("invalid tag parameter")
+		}
+	}
+	return f, nil
+}
+
+// match reports whether a must be deleted, a must match all the
+// filters.
+func (f *deleteFilter) match(a *article) bool {
+	if !f.before.IsZero() && !a.Timestamp.Before(f.before) {
+		return false
+	}
+	return f.tag == "" || a.hasTag(f.tag)
+}
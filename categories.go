@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errUnknownCategory = errors.New("unknown category")
+	errUnknownParent   = errors.New("unknown parent category")
+	errCategoryInUse   = errors.New("category has subcategories or articles")
+)
+
+// category groups articles. Categories are nested, the path of a
+// category is the path of its parent followed by a slash and its own
+// segment, e.g. "tech/go".
+type category struct {
+	Path        string `json:"path"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// parent returns the path of the parent category, or an empty string
+// for a top level category.
+func (c *category) parent() string {
+	i := strings.LastIndexByte(c.Path, '/')
+	if i < 0 {
+		return ""
+	}
+	return c.Path[:i]
+}
+
+// normalizeCategory lowercases the segments of a category path and
+// trims their spaces.
+func normalizeCategory(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || strings.IndexByte(s, 0) >= 0 {
+			return "", invalidError("invalid category")
+		}
+		segments[i] = s
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// inCategory reports whether the category of a is path or one of its
+// subcategories.
+func (a *article) inCategory(path string) bool {
+	return a.Category == path || strings.HasPrefix(a.Category, path+"/")
+}
+
+// checkCategory returns an invalidError if the category path of user
+// id does not exist.
+func (s *server) checkCategory(id, path string) error {
+	if path == "" {
+		return nil
+	}
+	_, err := s.store.Category(id, path)
+	if err == errUnknownCategory {
+		return invalidError(err.Error())
+	}
+	return err
+}
+
+func (s *server) getCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	categories, err := s.storeOf(r).Categories(id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	if categories == nil {
+		categories = []*category{}
+	}
+	writeResponse(w, r, categories)
+}
+
+func (s *server) postCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	c := &category{}
+	err := decodeRequest(r, c)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Path, err = normalizeCategory(c.Path)
+	if err == nil && c.Path == "" {
+		err = errors.New("missing path")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if c.Name == "" {
+		c.Name = c.Path[strings.LastIndexByte(c.Path, '/')+1:]
+	}
+
+	err = s.storeOf(r).PutCategory(id, c)
+	if err == errUnknownParent {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	writeResponse(w, r, c)
+}
+
+func (s *server) deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	path, err := normalizeCategory(params["path"])
+	if err == nil && path == "" {
+		err = errors.New("missing path")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = s.storeOf(r).DeleteCategory(id, path)
+	if err == errUnknownCategory {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errCategoryInUse {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// command is a command of blog-api, run with the configuration and the
+// arguments following its name.
+type command struct {
+	name  string
+	usage string
+	help  string
+	// maxArgs is the number of arguments accepted, -1 when the command
+	// parses its flags.
+	maxArgs int
+	run     func(cfg *config, args []string)
+}
+
+// commands lists the commands, serve runs without one.
+var commands = []*command{
+	{"serve", "serve", "serve the API, the default", 0, serveCommand},
+	{"export", "export [file]", "write the articles as ndjson to file, or the standard output", 1, exportCommand},
+	{"import", "import [file]", "store the articles exported to file, or the standard input", 1, importCommand},
+	{"export-site", "export-site [-out public] -url url", "write the published articles as a static site", -1, exportSiteCommand},
+	{"import-wordpress", "import-wordpress file -user id", "store the posts of a WordPress export as the articles of an user", -1, importWordpressCommand},
+	{"import-md", "import-md dir -user id", "store the Markdown files of dir as the articles of an user", -1, importMarkdownCommand},
+	{"restore", "restore file", "replace the database with the backup file", 1, restoreCommand},
+	{"compact", "compact", "reclaim the unused space of the database", 0, compactCommand},
+	{"migrate", "migrate", "apply the missing migrations of the database", 0, migrateCommand},
+	{"keys", "keys create|list|revoke", "create, list or revoke the API keys", -1, keysCommand},
+	{"seed", "seed [-users 3] [-articles 50] [-seed n]", "store random articles for demos", -1, seedCommand},
+	{"encrypt", "encrypt", "encrypt the existing articles with BLOG_API_ENCRYPTION_KEY", 0, encryptCommand},
+	{"reindex", "reindex", "rebuild the search index", 0, reindexCommand},
+}
+
+// findCommand returns the command name, nil when unknown.
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// mustOpenStore opens the store of cfg, exiting on failure.
+func mustOpenStore(cfg *config) Store {
+	store, err := openStore(cfg)
+	if err != nil {
+		fatal("fail to open DB", "err", err)
+	}
+	return store
+}
+
+// exportCommand writes all the articles to the file of args, or to the
+// standard output.
+func exportCommand(cfg *config, args []string) {
+	store := mustOpenStore(cfg)
+	defer store.Close()
+	var w io.WriteCloser = os.Stdout
+	if len(args) > 0 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			fatal("fail to create export", "err", err)
+		}
+		w = f
+	}
+	n, err := exportArticles(store, w)
+	if err != nil {
+		fatal("fail to export DB", "err", err, "exported", n)
+	}
+	err = w.Close()
+	if err != nil {
+		fatal("fail to write export", "err", err)
+	}
+	slog.Info("exported articles", "count", n)
+}
+
+// importCommand stores the articles exported to the file of args, or to
+// the standard input, and indexes them when a search index is set.
+func importCommand(cfg *config, args []string) {
+	store, _, err := openIndexedStore(cfg)
+	if err != nil {
+		fatal("fail to open DB", "err", err)
+	}
+	defer store.Close()
+	var r io.Reader = os.Stdin
+	if len(args) > 0 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fatal("fail to open export", "err", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	n, err := importArticles(store, r)
+	if err != nil {
+		fatal("fail to import DB", "err", err, "imported", n)
+	}
+	slog.Info("imported articles", "count", n)
+}
+
+// compactCommand reclaims the unused space of the database.
+func compactCommand(cfg *config, args []string) {
+	store := mustOpenStore(cfg)
+	defer store.Close()
+	c, ok := store.(compacter)
+	if !ok {
+		fatal("compaction not supported by the store")
+	}
+	before, after, err := c.Compact()
+	if err != nil {
+		fatal("fail to compact DB", "err", err)
+	}
+	slog.Info("compacted DB", "before", before, "after", after, "reclaimed", before-after)
+}
+
+// migrateCommand applies the missing migrations, opening the database
+// does, and logs its schema version.
+func migrateCommand(cfg *config, args []string) {
+	store := mustOpenStore(cfg)
+	defer store.Close()
+	b, ok := store.(*boltStore)
+	if !ok {
+		fatal("migrations not supported by the store")
+	}
+	v, err := b.SchemaVersion()
+	if err != nil {
+		fatal("fail to access DB", "err", err)
+	}
+	slog.Info("database is up to date", "version", v)
+}
+
+// keysCommand manages the API keys, without the admin key, e.g. to
+// create the first admin key.
+func keysCommand(cfg *config, args []string) {
+	if len(args) == 0 {
+		fatal("missing keys command, expected create, list or revoke")
+	}
+	name, args := args[0], args[1:]
+	var run func(keys keyStore, args []string)
+	switch name {
+	case "create":
+		run = createKeyCommand
+	case "list":
+		run = listKeysCommand
+	case "revoke":
+		run = revokeKeyCommand
+	default:
+		fatal("unknown keys command, expected create, list or revoke", "command", name)
+	}
+	store := mustOpenStore(cfg)
+	defer store.Close()
+	keys, ok := store.(keyStore)
+	if !ok {
+		fatal("API keys not supported by the store")
+	}
+	run(keys, args)
+}
+
+// createKeyCommand issues a key and prints its secret.
+func createKeyCommand(keys keyStore, args []string) {
+	fs := flag.NewFlagSet("keys create", flag.ExitOnError)
+	name := fs.String("name", "", "name of the key")
+	role := fs.String("role", roleEditor, "role of the key: admin, editor or author")
+	user := fs.String("user", "", "user of an author key")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fatal("too many arguments", "command", "keys create", "args", fs.Args())
+	}
+	k, secret, err := newAPIKey(*name, *user, *role)
+	if err != nil {
+		fatal("fail to create key", "err", err)
+	}
+	found, err := hasKeys(keys)
+	if err != nil {
+		fatal("fail to access DB", "err", err)
+	}
+	err = keys.PutKey(hashKey(secret), k)
+	if err != nil {
+		fatal("fail to access DB", "err", err)
+	}
+	slog.Info("created key", "id", k.ID, "role", k.Role, "user", k.User)
+	if !found {
+		// The server looks for the keys when it starts only.
+		slog.Warn("first API key created, a server started without credentials requires the keys once restarted")
+	}
+	// The secret is only printed once.
+	fmt.Println(secret)
+}
+
+// listKeysCommand prints the keys, without their secret.
+func listKeysCommand(keys keyStore, args []string) {
+	if len(args) > 0 {
+		fatal("too many arguments", "command", "keys list", "args", args)
+	}
+	list, err := keys.Keys()
+	if err != nil {
+		fatal("fail to access DB", "err", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tROLE\tUSER\tCREATED")
+	for _, k := range list {
+		role := k.Role
+		if role == "" {
+			role = roleEditor
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, role, k.User, k.Created.Format(time.RFC3339))
+	}
+	w.Flush()
+}
+
+// revokeKeyCommand deletes the keys of args.
+func revokeKeyCommand(keys keyStore, args []string) {
+	if len(args) == 0 {
+		fatal("missing ID of the key")
+	}
+	for _, id := range args {
+		err := keys.DeleteKey(id)
+		if err != nil {
+			fatal("fail to revoke key", "err", err, "id", id)
+		}
+		slog.Info("revoked key", "id", id)
+	}
+}
+
+// encryptCommand encrypts the plain text articles of the database.
+func encryptCommand(cfg *config, args []string) {
+	store := mustOpenStore(cfg)
+	defer store.Close()
+	b, ok := store.(*boltStore)
+	if !ok {
+		fatal("encryption not supported by the store")
+	}
+	n, err := b.encryptAll()
+	if err != nil {
+		fatal("fail to encrypt articles", "err", err)
+	}
+	slog.Info("encrypted articles", "count", n)
+}
+
+// reindexCommand rebuilds the search index from the articles of the
+// database.
+func reindexCommand(cfg *config, args []string) {
+	if cfg.SearchIndex == "" {
+		fatal("BLOG_API_SEARCH_INDEX is not set")
+	}
+	store := mustOpenStore(cfg)
+	defer store.Close()
+	err := os.RemoveAll(cfg.SearchIndex)
+	if err != nil {
+		fatal("fail to remove search index", "err", err)
+	}
+	idx, err := openSearchIndex(cfg.SearchIndex, store, false)
+	if err != nil {
+		fatal("fail to build search index", "err", err)
+	}
+	err = idx.Close()
+	if err != nil {
+		fatal("fail to close search index", "err", err)
+	}
+}
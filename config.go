@@ -0,0 +1,471 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ulule/limiter"
+)
+
+// config holds the server settings.
+type config struct {
+	Addr string
+	DB   string
+	// ReadOnly rejects the requests modifying the articles and opens
+	// the database read-only.
+	ReadOnly bool
+	// BasePath is the path the API is mounted under, e.g. /api/v1,
+	// empty at the root.
+	BasePath string
+	// AdminAddr is the address serving the admin endpoints to the
+	// operators, the public address does not when set.
+	AdminAddr string
+	// SocketMode and SocketGroup are the permissions and the group of
+	// the unix socket of Addr, the group is left unchanged when empty.
+	SocketMode  os.FileMode
+	SocketGroup string
+
+	// BackupDir is the directory receiving the scheduled backups,
+	// backups are disabled when empty.
+	BackupDir      string
+	BackupSchedule string
+	// BackupKeep is the number of scheduled backups to keep, zero
+	// keeps everything.
+	BackupKeep int
+
+	// TrashRetention is how long deleted articles stay in the trash.
+	TrashRetention time.Duration
+
+	// EncryptionKey is the base64 encoded AES key encrypting the
+	// articles at rest, articles are stored in plain text when empty.
+	EncryptionKey string
+
+	// SearchIndex is the directory of the full-text search index, the
+	// search uses substring matching when empty.
+	SearchIndex string
+
+	// CompressLevel is the gzip level of the responses, zero disables
+	// the compression.
+	CompressLevel int
+
+	// AdminKey is the key of the admin endpoints, it enables the API
+	// key authentication of the requests modifying data when set.
+	AdminKey string
+
+	// JWTKey is the secret of the tokens signed with HMAC and JWKSURL
+	// the URL of the public keys of the other tokens. Setting either
+	// lets the users modify their articles with a token whose subject
+	// is their ID.
+	JWTKey  string
+	JWKSURL string
+	// JWTIssuer and JWTAudience are checked when not empty.
+	JWTIssuer   string
+	JWTAudience string
+
+	// CORS is the policy of the cross-origin requests.
+	CORS corsPolicy
+
+	// TLSCert and TLSKey are the PEM files of the certificate and its
+	// key, the server uses HTTPS when they are set.
+	TLSCert string
+	TLSKey  string
+	// TLSClientCA is the PEM file of the authorities of the client
+	// certificates, the clients need none when empty.
+	TLSClientCA string
+
+	// ACMEDomains are the domains whose certificates are obtained from
+	// Let's Encrypt, ACMECache is the directory keeping them and
+	// ACMEHTTPAddr the address redirecting HTTP to HTTPS.
+	ACMEDomains  []string
+	ACMECache    string
+	ACMEEmail    string
+	ACMEHTTPAddr string
+
+	// MaxBodySize is the largest request body accepted in bytes, zero
+	// accepts any size.
+	MaxBodySize int64
+
+	// RateRead, RateWrite and RateAdmin are the rates allowed to a
+	// client for the reads, the writes and the admin endpoints, a zero
+	// rate is not limited.
+	RateRead  limiter.Rate
+	RateWrite limiter.Rate
+	RateAdmin limiter.Rate
+
+	// IPAllow are the networks allowed to call the API, IPDeny the ones
+	// rejected and AdminIPAllow the ones allowed to call the admin
+	// endpoints and to delete. Empty allow lists allow every network.
+	IPAllow      []*net.IPNet
+	IPDeny       []*net.IPNet
+	AdminIPAllow []*net.IPNet
+	// TrustedProxies are the proxies whose X-Forwarded-For, X-Real-IP
+	// and X-Forwarded-Proto headers are trusted, nil when none.
+	TrustedProxies *trustedProxies
+
+	// IdempotencyTTL is how long the responses to the requests with an
+	// Idempotency-Key are remembered, zero ignores the header.
+	IdempotencyTTL time.Duration
+
+	// LogFormat is the format of the logs, either json or text.
+	LogFormat string
+	// LogLevel is the level of the logs at startup.
+	LogLevel slog.Level
+	// SlowRequest is the duration of the requests logged as slow, zero
+	// logs none.
+	SlowRequest time.Duration
+	// AccessLog is the file receiving the access log, or syslog, it is
+	// written with the other logs when empty.
+	AccessLog string
+	// AccessLogMaxSize is the size in megabytes of an access log file
+	// before it is rotated.
+	AccessLogMaxSize int
+	// AccessLogRotation is how often the access log file is rotated,
+	// zero only rotates it on size.
+	AccessLogRotation time.Duration
+	// AccessLogKeep is the number of rotated files to keep, zero keeps
+	// everything.
+	AccessLogKeep int
+
+	// OTLPEndpoint is the URL of the OTLP/HTTP collector receiving the
+	// traces, tracing is disabled when empty.
+	OTLPEndpoint string
+	// ServiceName is the name of the server in the traces.
+	ServiceName string
+
+	// DebugAddr is the loopback address serving the debug endpoints
+	// without authentication, disabled when empty.
+	DebugAddr string
+
+	// ShutdownTimeout is how long the requests in flight are given to
+	// complete on shutdown.
+	ShutdownTimeout time.Duration
+
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout and IdleTimeout are
+	// the timeouts of the connections, see http.Server, zero disables
+	// them.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// MaxHeaderBytes is the size of the largest request headers.
+	MaxHeaderBytes int
+
+	// SentryDSN is the Sentry DSN receiving the server errors, they are
+	// only logged when empty.
+	SentryDSN string
+}
+
+// loadConfig reads the configuration from the variables set by flags,
+// then from the environment, and from the file named by BLOG_API_CONFIG
+// for the variables not set.
+func loadConfig(flags map[string]string) (*config, error) {
+	env, err := newSettings(flags)
+	if err != nil {
+		return nil, fmt.Errorf("BLOG_API_CONFIG: %v", err)
+	}
+	cfg, err := readConfig(env)
+	if err != nil {
+		return nil, err
+	}
+	err = env.checkUnknown()
+	if err != nil {
+		return nil, fmt.Errorf("BLOG_API_CONFIG: %v", err)
+	}
+	return cfg, nil
+}
+
+// readConfig reads the configuration from env.
+func readConfig(env *settings) (*config, error) {
+	cfg := &config{
+		Addr:           env.get("BLOG_API_ADDR", ":8080"),
+		DB:             env.get("BLOG_API_DB", "blog.db"),
+		BackupDir:      env.get("BLOG_API_BACKUP_DIR", ""),
+		BackupSchedule: env.get("BLOG_API_BACKUP_SCHEDULE", "0 3 * * *"),
+		EncryptionKey:  env.get("BLOG_API_ENCRYPTION_KEY", ""),
+		SearchIndex:    env.get("BLOG_API_SEARCH_INDEX", ""),
+		AdminKey:       env.get("BLOG_API_ADMIN_KEY", ""),
+		JWTKey:         env.get("BLOG_API_JWT_KEY", ""),
+		JWKSURL:        env.get("BLOG_API_JWKS_URL", ""),
+		JWTIssuer:      env.get("BLOG_API_JWT_ISSUER", ""),
+		JWTAudience:    env.get("BLOG_API_JWT_AUDIENCE", ""),
+		TLSCert:        env.get("BLOG_API_TLS_CERT", ""),
+		TLSKey:         env.get("BLOG_API_TLS_KEY", ""),
+		TLSClientCA:    env.get("BLOG_API_TLS_CLIENT_CA", ""),
+		ACMEDomains:    env.getList("BLOG_API_ACME_DOMAIN", ""),
+		ACMECache:      env.get("BLOG_API_ACME_CACHE", "acme-cache"),
+		ACMEEmail:      env.get("BLOG_API_ACME_EMAIL", ""),
+		ACMEHTTPAddr:   env.get("BLOG_API_ACME_HTTP_ADDR", ":80"),
+		LogFormat:      env.get("BLOG_API_LOG_FORMAT", "json"),
+		AccessLog:      env.get("BLOG_API_ACCESS_LOG", ""),
+		OTLPEndpoint:   env.get("BLOG_API_OTLP_ENDPOINT", ""),
+		ServiceName:    env.get("BLOG_API_SERVICE_NAME", "blog-api"),
+		DebugAddr:      env.get("BLOG_API_DEBUG_ADDR", ""),
+		SentryDSN:      env.get("BLOG_API_SENTRY_DSN", ""),
+		SocketGroup:    env.get("BLOG_API_SOCKET_GROUP", ""),
+		AdminAddr:      env.get("BLOG_API_ADMIN_ADDR", ""),
+	}
+	if cfg.LogFormat != "json" && cfg.LogFormat != "text" {
+		return nil, fmt.Errorf("BLOG_API_LOG_FORMAT: invalid format %q", cfg.LogFormat)
+	}
+	var err error
+	cfg.LogLevel, err = parseLogLevel(env.get("BLOG_API_LOG_LEVEL", "info"))
+	if err != nil {
+		return nil, fmt.Errorf("BLOG_API_LOG_LEVEL: %v", err)
+	}
+	if cfg.DebugAddr != "" {
+		err = checkLoopback(cfg.DebugAddr)
+		if err != nil {
+			return nil, fmt.Errorf("BLOG_API_DEBUG_ADDR: %v", err)
+		}
+	}
+	cfg.BasePath, err = parseBasePath(env.get("BLOG_API_BASE_PATH", ""))
+	if err != nil {
+		return nil, fmt.Errorf("BLOG_API_BASE_PATH: %v", err)
+	}
+	mode := env.get("BLOG_API_SOCKET_MODE", "0660")
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0777 {
+		return nil, fmt.Errorf("BLOG_API_SOCKET_MODE: invalid mode %q", mode)
+	}
+	cfg.SocketMode = os.FileMode(perm)
+	cfg.ReadOnly, err = env.getBool("BLOG_API_READONLY", false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.BackupKeep, err = env.getInt("BLOG_API_BACKUP_KEEP", 7)
+	if err != nil {
+		return nil, err
+	}
+	slowMillis, err := env.getInt("BLOG_API_SLOW_REQUEST_MS", 1000)
+	if err != nil {
+		return nil, err
+	}
+	if slowMillis < 0 {
+		return nil, fmt.Errorf("BLOG_API_SLOW_REQUEST_MS: invalid duration %d", slowMillis)
+	}
+	cfg.SlowRequest = time.Duration(slowMillis) * time.Millisecond
+	cfg.AccessLogMaxSize, err = env.getInt("BLOG_API_ACCESS_LOG_MAX_SIZE_MB", 100)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AccessLogMaxSize <= 0 {
+		return nil, fmt.Errorf("BLOG_API_ACCESS_LOG_MAX_SIZE_MB: invalid size %d", cfg.AccessLogMaxSize)
+	}
+	rotateHours, err := env.getInt("BLOG_API_ACCESS_LOG_ROTATE_HOURS", 24)
+	if err != nil {
+		return nil, err
+	}
+	if rotateHours < 0 {
+		return nil, fmt.Errorf("BLOG_API_ACCESS_LOG_ROTATE_HOURS: invalid interval %d", rotateHours)
+	}
+	cfg.AccessLogRotation = time.Duration(rotateHours) * time.Hour
+	cfg.AccessLogKeep, err = env.getInt("BLOG_API_ACCESS_LOG_KEEP", 7)
+	if err != nil {
+		return nil, err
+	}
+	days, err := env.getInt("BLOG_API_TRASH_DAYS", 30)
+	if err != nil {
+		return nil, err
+	}
+	cfg.TrashRetention = time.Duration(days) * 24 * time.Hour
+	cfg.CompressLevel, err = env.getInt("BLOG_API_COMPRESS_LEVEL", gzip.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CompressLevel < gzip.HuffmanOnly || cfg.CompressLevel > gzip.BestCompression {
+		return nil, fmt.Errorf("BLOG_API_COMPRESS_LEVEL: invalid level %d", cfg.CompressLevel)
+	}
+	cfg.CORS = corsPolicy{
+		Origins: env.getList("BLOG_API_CORS_ORIGINS", "*"),
+		Methods: env.getList("BLOG_API_CORS_METHODS", "GET, POST, PUT, PATCH, OPTIONS, DELETE"),
+		Headers: env.getList("BLOG_API_CORS_HEADERS", "Content-Type, If-Match, If-None-Match, Authorization, X-API-Key, X-CSRF-Token, Idempotency-Key, X-Request-ID"),
+		Expose:  []string{"X-Total-Count", "Link", "ETag", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After", "Idempotent-Replayed", "X-Request-ID"},
+	}
+	cfg.CORS.Credentials, err = env.getBool("BLOG_API_CORS_CREDENTIALS", false)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CORS.Credentials && cfg.CORS.allowAll() {
+		return nil, errors.New("BLOG_API_CORS_CREDENTIALS: credentials cannot be allowed to every origin")
+	}
+	maxBody, err := env.getInt("BLOG_API_MAX_BODY_SIZE", 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	if maxBody < 0 {
+		return nil, fmt.Errorf("BLOG_API_MAX_BODY_SIZE: invalid size %d", maxBody)
+	}
+	cfg.MaxBodySize = int64(maxBody)
+	cfg.RateRead, err = env.getRate("BLOG_API_RATE_READ", "264/1m")
+	if err != nil {
+		return nil, err
+	}
+	cfg.RateWrite, err = env.getRate("BLOG_API_RATE_WRITE", "264/1m")
+	if err != nil {
+		return nil, err
+	}
+	cfg.RateAdmin, err = env.getRate("BLOG_API_RATE_ADMIN", "264/1m")
+	if err != nil {
+		return nil, err
+	}
+	hours, err := env.getInt("BLOG_API_IDEMPOTENCY_HOURS", 24)
+	if err != nil {
+		return nil, err
+	}
+	cfg.IdempotencyTTL = time.Duration(hours) * time.Hour
+	cfg.ShutdownTimeout, err = env.getSeconds("BLOG_API_SHUTDOWN_SECONDS", 30)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ReadTimeout, err = env.getSeconds("BLOG_API_READ_TIMEOUT_SECONDS", 60)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ReadHeaderTimeout, err = env.getSeconds("BLOG_API_READ_HEADER_TIMEOUT_SECONDS", 10)
+	if err != nil {
+		return nil, err
+	}
+	cfg.WriteTimeout, err = env.getSeconds("BLOG_API_WRITE_TIMEOUT_SECONDS", 120)
+	if err != nil {
+		return nil, err
+	}
+	cfg.IdleTimeout, err = env.getSeconds("BLOG_API_IDLE_TIMEOUT_SECONDS", 120)
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxHeaderBytes, err = env.getInt("BLOG_API_MAX_HEADER_BYTES", http.DefaultMaxHeaderBytes)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxHeaderBytes < 0 {
+		return nil, fmt.Errorf("BLOG_API_MAX_HEADER_BYTES: invalid size %d", cfg.MaxHeaderBytes)
+	}
+	cfg.IPAllow, err = env.getCIDRs("BLOG_API_IP_ALLOW")
+	if err != nil {
+		return nil, err
+	}
+	cfg.IPDeny, err = env.getCIDRs("BLOG_API_IP_DENY")
+	if err != nil {
+		return nil, err
+	}
+	cfg.AdminIPAllow, err = env.getCIDRs("BLOG_API_ADMIN_IP_ALLOW")
+	if err != nil {
+		return nil, err
+	}
+	cfg.TrustedProxies, err = parseTrustedProxies(env.getList("BLOG_API_TRUSTED_PROXIES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("BLOG_API_TRUSTED_PROXIES: %v", err)
+	}
+	if _, ok := unixSocketPath(cfg.Addr); ok && (cfg.TrustedProxies == nil || !cfg.TrustedProxies.unix) && (len(cfg.IPAllow) > 0 || len(cfg.IPDeny) > 0 || len(cfg.AdminIPAllow) > 0) {
+		return nil, errors.New("BLOG_API_IP_ALLOW, BLOG_API_IP_DENY and BLOG_API_ADMIN_IP_ALLOW: the clients of a unix socket have no IP to filter, unless BLOG_API_TRUSTED_PROXIES trusts unix")
+	}
+	maxAge, err := env.getInt("BLOG_API_CORS_MAX_AGE", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CORS.MaxAge = time.Duration(maxAge) * time.Second
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, errors.New("BLOG_API_TLS_CERT and BLOG_API_TLS_KEY must be set together")
+	}
+	if len(cfg.ACMEDomains) > 0 {
+		if cfg.TLSCert != "" {
+			return nil, errors.New("BLOG_API_ACME_DOMAIN: cannot be used with BLOG_API_TLS_CERT")
+		}
+		if env.get("BLOG_API_ADDR", "") == "" {
+			cfg.Addr = ":443"
+		}
+	}
+	if cfg.TLSClientCA != "" && cfg.TLSCert == "" && len(cfg.ACMEDomains) == 0 {
+		return nil, errors.New("BLOG_API_TLS_CLIENT_CA: requires BLOG_API_TLS_CERT or BLOG_API_ACME_DOMAIN")
+	}
+	if cfg.JWKSURL != "" {
+		u, err := url.Parse(cfg.JWKSURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("BLOG_API_JWKS_URL: invalid URL %q", cfg.JWKSURL)
+		}
+	}
+	return cfg, nil
+}
+
+// get returns the value of key, def when empty.
+func (s *settings) get(key, def string) string {
+	v := s.lookup(key)
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// getList returns the comma separated values of key.
+func (s *settings) getList(key, def string) []string {
+	var list []string
+	for _, v := range strings.Split(s.get(key, def), ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func (s *settings) getInt(key string, def int) (int, error) {
+	v := s.lookup(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid integer %q", key, v)
+	}
+	return n, nil
+}
+
+// getSeconds returns the duration of key, a number of seconds.
+func (s *settings) getSeconds(key string, def int) (time.Duration, error) {
+	n, err := s.getInt(key, def)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s: invalid duration %d", key, n)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+func (s *settings) getRate(key, def string) (limiter.Rate, error) {
+	v := s.get(key, def)
+	rate, err := parseRate(v)
+	if err != nil {
+		return rate, fmt.Errorf("%s: %v in %q", key, err, v)
+	}
+	return rate, nil
+}
+
+func (s *settings) getCIDRs(key string) ([]*net.IPNet, error) {
+	networks, err := parseCIDRs(s.getList(key, ""))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	return networks, nil
+}
+
+func (s *settings) getBool(key string, def bool) (bool, error) {
+	v := s.lookup(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid boolean %q", key, v)
+	}
+	return b, nil
+}
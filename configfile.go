@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ghodss/yaml"
+)
+
+// settings are the values of the BLOG_API_* variables, set by the flags,
+// read from the environment or else from the configuration file.
+type settings struct {
+	flags map[string]string
+	// file are the values of the configuration file by variable, names
+	// their key in the file.
+	file  map[string]string
+	names map[string]string
+	// read are the variables read, the other ones of the file are
+	// unknown.
+	read map[string]bool
+}
+
+// newSettings returns the settings of flags, of the environment and of
+// the configuration file named by BLOG_API_CONFIG, if any.
+func newSettings(flags map[string]string) (*settings, error) {
+	s := &settings{
+		flags: flags,
+		file:  make(map[string]string),
+		names: make(map[string]string),
+		read:  make(map[string]bool),
+	}
+	path := s.lookup("BLOG_API_CONFIG")
+	if path == "" {
+		return s, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unknown format of %s, expected .yaml, .yml, .json or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	err = s.add("", values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// add adds the values of the file under the key prefix. The keys of the
+// nested tables are joined with underscores, e.g. rate.read is
+// BLOG_API_RATE_READ, and the lists with commas.
+func (s *settings) add(prefix string, values map[string]interface{}) error {
+	for k, v := range values {
+		name := k
+		if prefix != "" {
+			name = prefix + "." + k
+		}
+		if table, ok := v.(map[string]interface{}); ok {
+			err := s.add(name, table)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := settingValue(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		key := "BLOG_API_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+		s.file[key] = value
+		s.names[key] = name
+	}
+	return nil
+}
+
+// settingValue returns v as the value of an environment variable.
+func settingValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, e := range v {
+			s, err := settingValue(e)
+			if err != nil {
+				return "", err
+			}
+			list[i] = s
+		}
+		return strings.Join(list, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// lookup returns the value of key, from the flags or the environment when
+// set, even empty, else from the file.
+func (s *settings) lookup(key string) string {
+	s.read[key] = true
+	if v, ok := s.flags[key]; ok {
+		return v
+	}
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return s.file[key]
+}
+
+// checkUnknown returns an error listing the settings of the file which
+// were not read, most likely misspelled.
+func (s *settings) checkUnknown() error {
+	var unknown []string
+	for key, name := range s.names {
+		if !s.read[key] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown settings %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsPolicy is the policy of the cross-origin requests.
+type corsPolicy struct {
+	// Origins are the allowed origins, "*" allows every origin.
+	Origins []string
+	Methods []string
+	Headers []string
+	// Expose are the response headers readable by the scripts.
+	Expose []string
+	// Credentials allows the cookies and the Authorization header, it
+	// requires a list of origins.
+	Credentials bool
+	// MaxAge is how long the browsers can cache a preflight response,
+	// zero leaves it to the browsers.
+	MaxAge time.Duration
+}
+
+func (p *corsPolicy) allowAll() bool {
+	for _, o := range p.Origins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin of a request from
+// origin, empty when the origin is not allowed.
+func (p *corsPolicy) allowOrigin(origin string) string {
+	if p.allowAll() {
+		return "*"
+	}
+	for _, o := range p.Origins {
+		if strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// corsMiddleware adds the CORS headers of p to the responses and answers
+// the preflight requests.
+func corsMiddleware(h http.Handler, p *corsPolicy) http.Handler {
+	methods := strings.Join(p.Methods, ", ")
+	headers := strings.Join(p.Headers, ", ")
+	expose := strings.Join(p.Expose, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.allowAll() {
+			w.Header().Add("Vary", "Origin")
+		}
+		origin := p.allowOrigin(r.Header.Get("Origin"))
+		if origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			w.Header().Set("Access-Control-Expose-Headers", expose)
+			if p.Credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if p.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge/time.Second)))
+			}
+		}
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression with the five standard
+// fields: minute, hour, day of month, month and day of week.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// anyDom and anyDow are set when the day of month or the day of
+	// week is a wildcard, in which case only the other one is matched.
+	anyDom, anyDow bool
+}
+
+type cronField struct {
+	min, max int
+}
+
+var cronFields = []cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week
+}
+
+// parseCron parses a cron expression such as "30 2 * * *". Each field
+// accepts wildcards, values, ranges, lists and steps.
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron: expected %d fields, got %d", len(cronFields), len(fields))
+	}
+	var bits [5]uint64
+	for i, f := range fields {
+		var err error
+		bits[i], err = parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron: field %q: %v", f, err)
+		}
+	}
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		anyDom: fields[2] == "*",
+		anyDow: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(s string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			part = part[:i]
+		}
+		lo, hi := f.min, f.max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			lo, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				hi, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("out of range [%d-%d]", f.min, f.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+// next returns the first time matching the schedule after t, or the
+// zero time if none is found within five years.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
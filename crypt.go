@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// sealedPrefix starts the encrypted values. Plain text records are JSON
+// objects and always start with '{'.
+const sealedPrefix = 0x01
+
+var (
+	errNoEncryptionKey = errors.New("record is encrypted but no encryption key is set")
+	errSealedRecord    = errors.New("malformed encrypted record")
+)
+
+// newCipher returns an AES-GCM cipher from a base64 encoded key of 16,
+// 24 or 32 bytes.
+func newCipher(key string) (cipher.AEAD, error) {
+	k, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %v", err)
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func isSealed(data []byte) bool {
+	return len(data) > 0 && data[0] == sealedPrefix
+}
+
+// seal encrypts data, the result holds the prefix, the nonce and the
+// cipher text.
+func seal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+len(nonce)+len(data)+aead.Overhead())
+	out = append(out, sealedPrefix)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, nil), nil
+}
+
+// unseal decrypts data produced by seal.
+func unseal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if aead == nil {
+		return nil, errNoEncryptionKey
+	}
+	if len(data) < 1+aead.NonceSize() {
+		return nil, errSealedRecord
+	}
+	nonce := data[1 : 1+aead.NonceSize()]
+	return aead.Open(nil, nonce, data[1+aead.NonceSize():], nil)
+}
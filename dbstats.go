@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dbStatser is implemented by the stores able to describe the use of
+// their database.
+type dbStatser interface {
+	// DBStats returns the statistics of the database.
+	DBStats() (*dbStats, error)
+}
+
+// dbStats are the statistics of a Bolt database.
+type dbStats struct {
+	// PageSize and Size are the size in bytes of a page and of the file.
+	PageSize int   `json:"page_size"`
+	Size     int64 `json:"size"`
+	// FreePages are the pages reused by the next writes, PendingPages
+	// the ones freed by the open transactions, FreeAlloc their size in
+	// bytes. Compacting reclaims the free pages.
+	FreePages     int `json:"free_pages"`
+	PendingPages  int `json:"pending_pages"`
+	FreeAlloc     int `json:"free_alloc"`
+	FreelistInuse int `json:"freelist_inuse"`
+	// ReadTxs is the number of read transactions started, OpenReadTxs
+	// the number of open ones.
+	ReadTxs     int `json:"read_txs"`
+	OpenReadTxs int `json:"open_read_txs"`
+	// Writes is the number of writes to the file and WriteSeconds the
+	// time spent writing.
+	Writes       int     `json:"writes"`
+	WriteSeconds float64 `json:"write_seconds"`
+	// Buckets are the statistics of the top-level buckets, including
+	// their nested buckets.
+	Buckets map[string]*bucketStats `json:"buckets"`
+}
+
+// bucketStats are the statistics of a Bolt bucket.
+type bucketStats struct {
+	Keys        int `json:"keys"`
+	Depth       int `json:"depth"`
+	Buckets     int `json:"buckets"`
+	BranchPages int `json:"branch_pages"`
+	LeafPages   int `json:"leaf_pages"`
+	// OverflowPages are the extra pages of the large nodes.
+	OverflowPages int `json:"overflow_pages"`
+	// LeafAlloc is the size in bytes of the leaf pages, LeafInuse the
+	// part holding data.
+	LeafAlloc int `json:"leaf_alloc"`
+	LeafInuse int `json:"leaf_inuse"`
+}
+
+// dbStatsHandler sends the statistics of the database.
+func (s *server) dbStatsHandler(w http.ResponseWriter, r *http.Request) {
+	st, ok := baseStore(s.store).(dbStatser)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "statistics not supported by the store")
+		return
+	}
+	stats, err := st.DBStats()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	writeResponse(w, r, stats)
+}
+
+// dbStatsCollector exports the statistics of a database to Prometheus.
+type dbStatsCollector struct {
+	store dbStatser
+}
+
+var (
+	dbPageSizeDesc = prometheus.NewDesc("blog_bolt_page_size_bytes",
+		"Size of a page of the database.", nil, nil)
+	dbSizeDesc = prometheus.NewDesc("blog_bolt_size_bytes",
+		"Size of the database file.", nil, nil)
+	dbFreePagesDesc = prometheus.NewDesc("blog_bolt_free_pages",
+		"Free pages of the database, reclaimed by a compaction.", nil, nil)
+	dbPendingPagesDesc = prometheus.NewDesc("blog_bolt_pending_pages",
+		"Pages freed by the open transactions.", nil, nil)
+	dbFreeAllocDesc = prometheus.NewDesc("blog_bolt_free_alloc_bytes",
+		"Size of the free pages.", nil, nil)
+	dbReadTxsDesc = prometheus.NewDesc("blog_bolt_read_tx_total",
+		"Read transactions started.", nil, nil)
+	dbOpenReadTxsDesc = prometheus.NewDesc("blog_bolt_open_read_tx",
+		"Open read transactions.", nil, nil)
+	dbWritesDesc = prometheus.NewDesc("blog_bolt_writes_total",
+		"Writes to the database file.", nil, nil)
+	dbWriteSecondsDesc = prometheus.NewDesc("blog_bolt_write_seconds_total",
+		"Time spent writing to the database file.", nil, nil)
+	dbBucketKeysDesc = prometheus.NewDesc("blog_bolt_bucket_keys",
+		"Keys of a top-level bucket and its nested buckets.", []string{"bucket"}, nil)
+	dbBucketLeafPagesDesc = prometheus.NewDesc("blog_bolt_bucket_leaf_pages",
+		"Leaf pages of a top-level bucket and its nested buckets.", []string{"bucket"}, nil)
+	dbBucketLeafAllocDesc = prometheus.NewDesc("blog_bolt_bucket_leaf_alloc_bytes",
+		"Size of the leaf pages of a top-level bucket.", []string{"bucket"}, nil)
+	dbBucketLeafInuseDesc = prometheus.NewDesc("blog_bolt_bucket_leaf_inuse_bytes",
+		"Size of the data in the leaf pages of a top-level bucket.", []string{"bucket"}, nil)
+)
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.store.DBStats()
+	if err != nil {
+		slog.Error("fail to access DB", "err", err)
+		return
+	}
+	gauge := func(desc *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, labels...)
+	}
+	counter := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+	gauge(dbPageSizeDesc, float64(stats.PageSize))
+	gauge(dbSizeDesc, float64(stats.Size))
+	gauge(dbFreePagesDesc, float64(stats.FreePages))
+	gauge(dbPendingPagesDesc, float64(stats.PendingPages))
+	gauge(dbFreeAllocDesc, float64(stats.FreeAlloc))
+	counter(dbReadTxsDesc, float64(stats.ReadTxs))
+	gauge(dbOpenReadTxsDesc, float64(stats.OpenReadTxs))
+	counter(dbWritesDesc, float64(stats.Writes))
+	counter(dbWriteSecondsDesc, stats.WriteSeconds)
+	for name, b := range stats.Buckets {
+		gauge(dbBucketKeysDesc, float64(b.Keys), name)
+		gauge(dbBucketLeafPagesDesc, float64(b.LeafPages), name)
+		gauge(dbBucketLeafAllocDesc, float64(b.LeafAlloc), name)
+		gauge(dbBucketLeafInuseDesc, float64(b.LeafInuse), name)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+)
+
+// handleDebug registers the profiling and expvar endpoints under
+// /debug/ on m.
+func handleDebug(m *mux.Router) {
+	m.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline).Methods("GET")
+	m.HandleFunc("/debug/pprof/profile", pprof.Profile).Methods("GET")
+	m.HandleFunc("/debug/pprof/symbol", pprof.Symbol).Methods("GET", "POST")
+	m.HandleFunc("/debug/pprof/trace", pprof.Trace).Methods("GET")
+	m.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index).Methods("GET")
+	m.Handle("/debug/vars", expvar.Handler()).Methods("GET")
+}
+
+// serveDebug serves the debug endpoints without authentication on the
+// debug address of cfg, which must be a loopback address.
+func serveDebug(cfg *config) error {
+	m := mux.NewRouter()
+	handleDebug(m)
+	return newHTTPServer(cfg, cfg.DebugAddr, m).ListenAndServe()
+}
+
+// checkLoopback returns an error if the host of addr is not a loopback
+// address.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%q is not a loopback address", host)
+	}
+	return nil
+}
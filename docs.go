@@ -0,0 +1,18 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// docsPage is the interactive API explorer, it lists the operations of
+// the OpenAPI description and sends the requests built with its forms.
+//
+//go:embed docs/index.html
+var docsPage []byte
+
+// docsHandler sends the API explorer.
+func (s *server) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/vmihailenco/msgpack"
+)
+
+// errInvalidContentType is returned when a request body has an
+// unsupported media type.
+var errInvalidContentType = errors.New("invalid content-type")
+
+// codec encodes and decodes the bodies of some media types.
+type codec struct {
+	// name is used in the error messages.
+	name string
+	// mediaTypes are the media types of the codec.
+	mediaTypes []string
+	marshal    func(v interface{}) ([]byte, error)
+	unmarshal  func(data []byte, v interface{}) error
+}
+
+// The YAML and MessagePack codecs go through encoding/json, the fields
+// keep their JSON names.
+var (
+	jsonCodec = &codec{
+		name:       "JSON",
+		mediaTypes: []string{"application/json"},
+		marshal:    marshalJSON,
+		unmarshal:  json.Unmarshal,
+	}
+	yamlCodec = &codec{
+		name:       "YAML",
+		mediaTypes: []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		marshal:    yaml.Marshal,
+		unmarshal:  yaml.Unmarshal,
+	}
+	msgpackCodec = &codec{
+		name:       "MessagePack",
+		mediaTypes: []string{"application/msgpack", "application/x-msgpack"},
+		marshal:    marshalMsgpack,
+		unmarshal:  unmarshalMsgpack,
+	}
+)
+
+// codecs are the supported codecs, JSON is the default one.
+var codecs = []*codec{jsonCodec, yamlCodec, msgpackCodec}
+
+// codecOf returns the codec of mediaType, or nil if it is not
+// supported.
+func codecOf(mediaType string) *codec {
+	for _, c := range codecs {
+		for _, t := range c.mediaTypes {
+			if t == mediaType {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// marshalJSON encodes v like json.Encoder, with a trailing newline.
+func marshalJSON(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := json.NewEncoder(buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+// marshalMsgpack encodes the JSON encoding of v in MessagePack.
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	err = dec.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	// The keys are sorted for the encoding to be stable, the ETags of
+	// the listings depend on it.
+	buf := &bytes.Buffer{}
+	enc := msgpack.NewEncoder(buf).SortMapKeys(true).UseCompactEncoding(true)
+	err = enc.Encode(fromJSONNumbers(doc))
+	return buf.Bytes(), err
+}
+
+// fromJSONNumbers replaces the json.Number of doc by integers when
+// possible, by floats otherwise.
+func fromJSONNumbers(doc interface{}) interface{} {
+	switch v := doc.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = fromJSONNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = fromJSONNumbers(e)
+		}
+	}
+	return doc
+}
+
+// unmarshalMsgpack decodes a MessagePack document into v through its
+// JSON encoding.
+func unmarshalMsgpack(data []byte, v interface{}) error {
+	var doc interface{}
+	err := msgpack.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// requestCodec returns the codec of the Content-Type of r.
+func requestCodec(r *http.Request) (*codec, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, errInvalidContentType
+	}
+	c := codecOf(mediaType)
+	if c == nil {
+		return nil, errInvalidContentType
+	}
+	return c, nil
+}
+
+// decodeRequest decodes the body of r into v according to its
+// Content-Type. The error is meant for the client.
+func decodeRequest(r *http.Request, v interface{}) error {
+	c, err := requestCodec(r)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	_, err = buf.ReadFrom(r.Body)
+	if err == nil {
+		err = c.unmarshal(buf.Bytes(), v)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to parse %s", c.name)
+	}
+	return nil
+}
+
+// codecTypes are the media types of the codecs, in order of
+// preference.
+var codecTypes = mediaTypesOf(codecs)
+
+func mediaTypesOf(codecs []*codec) []string {
+	var types []string
+	for _, c := range codecs {
+		types = append(types, c.mediaTypes...)
+	}
+	return types
+}
+
+// responseType negotiates the media type of the response to r among
+// offers. When no offer is acceptable, it replies 406 Not Acceptable
+// and returns an empty string.
+func responseType(w http.ResponseWriter, r *http.Request, offers []string) string {
+	w.Header().Add("Vary", "Accept")
+	mediaType := negotiate(r, offers)
+	if mediaType == "" {
+		writeError(w, http.StatusNotAcceptable, "not acceptable, supported types: "+strings.Join(offers, ", "))
+	}
+	return mediaType
+}
+
+// writeResponse encodes v with the codec negotiated for r.
+func writeResponse(w http.ResponseWriter, r *http.Request, v interface{}) {
+	mediaType := responseType(w, r, codecTypes)
+	if mediaType == "" {
+		return
+	}
+	writeEncoded(w, mediaType, v)
+}
+
+// writeEncoded encodes v with the codec of mediaType.
+func writeEncoded(w http.ResponseWriter, mediaType string, v interface{}) {
+	data, err := codecOf(mediaType).marshal(v)
+	if err != nil {
+		slog.Error("encoding fail", "err", err)
+		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Write(data)
+}
+
+// xmlType is the media type of the XML encoding of the articles.
+const xmlType = "text/xml"
+
+// articleTypes are the media types of the articles, in order of
+// preference.
+var articleTypes = append(codecTypes[:len(codecTypes):len(codecTypes)], jsonAPIType, halType, xmlType, protobufType)
+
+// singleTypes are the media types of a single article, its content
+// can also be rendered as HTML.
+var singleTypes = append(articleTypes[:len(articleTypes):len(articleTypes)], htmlType)
+
+// decodeArticle decodes the body of r into a according to its
+// Content-Type, articles can also be sent as JSON:API, XML or protobuf.
+// The unknown fields of the codecs are rejected. The error is meant for
+// the client.
+func decodeArticle(r *http.Request, a *article) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != jsonAPIType && mediaType != xmlType && mediaType != protobufType) {
+		return decodeStrict(r, a)
+	}
+	buf := &bytes.Buffer{}
+	_, err = buf.ReadFrom(r.Body)
+	if err != nil {
+		return errors.New("fail to read body")
+	}
+	switch mediaType {
+	case jsonAPIType:
+		err = unmarshalJSONAPIArticle(buf.Bytes(), a)
+		if err != nil {
+			return errors.New("fail to parse JSON:API")
+		}
+		return nil
+	case xmlType:
+		err = xml.Unmarshal(buf.Bytes(), a)
+		if err != nil {
+			return errors.New("fail to parse XML")
+		}
+		return nil
+	}
+	err = unmarshalArticle(buf.Bytes(), a)
+	if err != nil {
+		return errors.New("fail to parse protobuf")
+	}
+	return nil
+}
+
+// writeArticle writes the given fields of a, all of them when fields is
+// nil.
+func writeArticle(w http.ResponseWriter, r *http.Request, a *article, fields []string) {
+	mediaType := responseType(w, r, singleTypes)
+	switch mediaType {
+	case "":
+	case htmlType:
+		writeHTML(w, a)
+	case jsonAPIType:
+		writeJSONAPIArticle(w, r, a, fields)
+	case halType:
+		writeHALArticle(w, r, a, fields)
+	case xmlType:
+		data, err := xml.Marshal(selectFields(a, fields))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "encoding fail", "err", err)
+			writeError(w, http.StatusInternalServerError, "encoding fail")
+			return
+		}
+		w.Header().Set("Content-Type", xmlType)
+		w.Write(data)
+	case protobufType:
+		w.Header().Set("Content-Type", protobufType)
+		w.Write(marshalArticle(selectFields(a, fields)))
+	default:
+		writeEncoded(w, mediaType, jsonArticle(a, fields))
+	}
+}
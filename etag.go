@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// errPreconditionFailed is returned when the If-Match header of a
+// request does not match the stored article.
+var errPreconditionFailed = errors.New("article was modified")
+
+// etagOf returns a strong entity tag computed from the hash of data.
+func etagOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// articleETag returns the entity tag of a, it changes whenever any
+// field of the article is modified.
+func articleETag(a *article) string {
+	data, err := json.Marshal(a)
+	if err != nil {
+		// An article always encodes to JSON.
+		panic(err)
+	}
+	return etagOf(data)
+}
+
+// etagMatch reports whether the list of entity tags of an If-Match or
+// If-None-Match header matches etag.
+func etagMatch(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header of the response, then replies 304
+// Not Modified and returns true when the If-None-Match header of the
+// request matches etag.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatch(header, etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// checkIfMatch returns errPreconditionFailed when the If-Match header
+// of r is set and does not match the ETag of a.
+func checkIfMatch(r *http.Request, a *article) error {
+	header := r.Header.Get("If-Match")
+	if header != "" && !etagMatch(header, articleETag(a)) {
+		return errPreconditionFailed
+	}
+	return nil
+}
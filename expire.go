@@ -0,0 +1,146 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// reapInterval is the delay between two deletions of the expired
+// articles.
+const reapInterval = time.Minute
+
+// reapExpired moves the expired articles to the trash, until stop is
+// closed.
+func reapExpired(store Store, stop <-chan struct{}) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		n, err := deleteExpired(store, time.Now())
+		if err != nil {
+			slog.Error("fail to delete expired articles", "err", err)
+		} else if n > 0 {
+			slog.Info("expired articles", "count", n)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dueStore is implemented by the stores indexing the articles by the
+// times they expire or are published at.
+type dueStore interface {
+	// Due returns the UUIDs of the articles of each user expiring or
+	// published at or before t.
+	Due(t time.Time) (map[string][]string, error)
+}
+
+// dueArticles returns the articles of each user for which due is true
+// at t. The index of a dueStore narrows the articles read, the other
+// stores are walked. Nothing is modified, the callers check the
+// articles again when they modify them.
+func dueArticles(store Store, t time.Time, due func(a *article) bool) (map[string][]*article, error) {
+	found := make(map[string][]*article)
+	if ds, ok := baseStore(store).(dueStore); ok {
+		uuids, err := ds.Due(t)
+		if err != nil {
+			return nil, err
+		}
+		for id, list := range uuids {
+			for _, uuid := range list {
+				a, err := store.GetByUUID(id, uuid)
+				if err == errUnknownID || err == errUnknownUUID {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				if due(a) {
+					found[id] = append(found[id], a)
+				}
+			}
+		}
+		return found, nil
+	}
+
+	users, err := store.Users()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range users {
+		err = store.Walk(id, byTitle, nil, func(a *article) bool {
+			if due(a) {
+				found[id] = append(found[id], a)
+			}
+			return true
+		})
+		if err == errUnknownID {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// deleteExpired moves the articles expired at time t to the trash and
+// returns how many were moved.
+func deleteExpired(store Store, t time.Time) (int, error) {
+	expired := func(a *article) bool {
+		return a.expired(t)
+	}
+	found, err := dueArticles(store, t, expired)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for id, articles := range found {
+		for _, a := range articles {
+			uuid := a.UUID
+			err = store.Delete(id, a.Title, func(a *article) error {
+				// The article may have changed since it was read.
+				if a.UUID != uuid || !expired(a) {
+					return errNotDue
+				}
+				return nil
+			})
+			if err == errUnknownID || err == errUnknownTitle || err == errNotDue {
+				continue
+			}
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Due reads the due index, without decoding the articles.
+func (s *boltStore) Due(t time.Time) (map[string][]string, error) {
+	due := make(map[string][]string)
+	err := s.view(func(tx *bolt.Tx) error {
+		root := tx.Bucket(dueIndexBucket)
+		return root.ForEach(func(id, _ []byte) error {
+			seen := make(map[string]bool)
+			c := root.Bucket(id).Cursor()
+			for k, _ := c.First(); k != nil && !keyTime(k).After(t); k, _ = c.Next() {
+				uuid := string(k[timeKeyLen:])
+				if !seen[uuid] {
+					seen[uuid] = true
+					due[string(id)] = append(due[string(id)], uuid)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
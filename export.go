@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// exportRecord is a line of an export, it holds an article with the ID
+// of its user.
+type exportRecord struct {
+	ID      string   `json:"id"`
+	Article *article `json:"article"`
+}
+
+// exportArticles writes all the articles of store to w as ndjson. It
+// returns the number of articles written.
+func exportArticles(store Store, w io.Writer) (int, error) {
+	users, err := store.Users()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	enc := json.NewEncoder(w)
+	for _, id := range users {
+		articles, err := store.List(id)
+		if err == errUnknownID {
+			// The user was deleted during the export.
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		for _, a := range articles {
+			err = enc.Encode(&exportRecord{ID: id, Article: a})
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// importBatchSize is the maximum number of articles imported in one
+// transaction.
+const importBatchSize = 500
+
+// importArticles reads articles exported by exportArticles from r and
+// stores them, replacing the articles with the same title. It returns
+// the number of articles imported.
+func importArticles(store Store, r io.Reader) (int, error) {
+	n := 0
+	line := 0
+	// Consecutive articles of the same user are stored in batches.
+	var batchID string
+	var batch []*article
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := store.PutAll(batchID, batch)
+		if err != nil {
+			return err
+		}
+		n += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := &exportRecord{}
+		err := json.Unmarshal(scanner.Bytes(), rec)
+		if err != nil {
+			return n, fmt.Errorf("line %d: %v", line, err)
+		}
+		if rec.ID == "" || rec.Article == nil || rec.Article.Title == "" {
+			return n, fmt.Errorf("line %d: missing ID or article", line)
+		}
+		err = validateArticle(rec.Article)
+		if err == nil {
+			rec.Article.Tags, err = normalizeTags(rec.Article.Tags)
+		}
+		if err == nil {
+			rec.Article.Metadata, err = normalizeMetadata(rec.Article.Metadata)
+		}
+		if err != nil {
+			return n, fmt.Errorf("line %d: %v", line, err)
+		}
+		if rec.Article.Status == "" {
+			rec.Article.Status = statusPublished
+		}
+		// Only a canonical slug is kept, the store assigns one otherwise.
+		if slugify(rec.Article.Slug) != rec.Article.Slug {
+			rec.Article.Slug = ""
+		}
+		rec.Article.schedule(time.Now())
+		if rec.ID != batchID || len(batch) == importBatchSize {
+			err = flush()
+			if err != nil {
+				return n, err
+			}
+			batchID = rec.ID
+		}
+		batch = append(batch, rec.Article)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return n, err
+	}
+	return n, flush()
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// feedSize is the number of articles of a feed when no limit is given.
+const feedSize = 20
+
+// baseURL returns the scheme, host and base path the request r was sent
+// to, the feeds hold absolute links.
+func baseURL(r *http.Request) string {
+	return requestScheme(r) + "://" + r.Host + basePathOf(r.Context())
+}
+
+// articleURL returns the absolute URL of the HTML of the article a of
+// user id.
+func articleURL(r *http.Request, id string, a *article) string {
+	return baseURL(r) + "/article/" + url.PathEscape(id) + "/" + url.PathEscape(a.Title) + "/html"
+}
+
+// parseFeedLimit reads the number of articles of a feed from the limit
+// query parameter.
+func parseFeedLimit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return feedSize, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxLimit {
+		return 0, errors.New("invalid limit parameter")
+	}
+	return n, nil
+}
+
+// latestArticles returns the limit latest published articles of user
+// id, the most recent first.
+func latestArticles(store Store, id string, limit int) ([]*article, error) {
+	q := &listQuery{
+		order:  byTimeDesc,
+		limit:  limit,
+		now:    time.Now(),
+		status: statusPublished,
+	}
+	articles, _, _, err := q.list(store, id)
+	return articles, err
+}
+
+// lastChange returns the time of the last change of a.
+func (a *article) lastChange() time.Time {
+	if a.Updated != nil {
+		return *a.Updated
+	}
+	return a.Timestamp
+}
+
+// lastUpdate returns the time of the last change of the articles.
+func lastUpdate(articles []*article) time.Time {
+	var t time.Time
+	for _, a := range articles {
+		if u := a.lastChange(); u.After(t) {
+			t = u
+		}
+	}
+	return t
+}
+
+// rssGUID is the unique ID of an RSS item.
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	GUID        rssGUID  `xml:"guid"`
+	Categories  []string `xml:"category"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name    `xml:"rss"`
+	Version string      `xml:"version,attr"`
+	Channel *rssChannel `xml:"channel"`
+}
+
+// newRSSFeed returns the RSS 2.0 feed of the articles of user id, home
+// is the URL of the articles and link returns the URL of an article.
+func newRSSFeed(id, home string, articles []*article, link func(a *article) string) *rssFeed {
+	channel := &rssChannel{
+		Title:       "Articles of " + id,
+		Link:        home,
+		Description: "The latest articles of " + id,
+	}
+	if len(articles) > 0 {
+		channel.LastBuildDate = lastUpdate(articles).Format(time.RFC1123Z)
+	}
+	for _, a := range articles {
+		item := &rssItem{
+			Title:       a.Title,
+			Link:        link(a),
+			Description: string(renderMarkdown(a.Content)),
+			PubDate:     a.Timestamp.Format(time.RFC1123Z),
+			GUID:        rssGUID{Value: "urn:uuid:" + a.UUID},
+		}
+		if a.Category != "" {
+			item.Categories = append(item.Categories, a.Category)
+		}
+		item.Categories = append(item.Categories, a.Tags...)
+		channel.Items = append(channel.Items, item)
+	}
+	return &rssFeed{Version: "2.0", Channel: channel}
+}
+
+// rssFeedHandler sends the latest published articles of an user as an
+// RSS 2.0 feed.
+func (s *server) rssFeedHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	limit, err := parseFeedLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	articles, err := latestArticles(s.storeOf(r), id, limit)
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	home := baseURL(r) + "/articles/" + url.PathEscape(id) + "/"
+	feed := newRSSFeed(id, home, articles, func(a *article) string {
+		return articleURL(r, id, a)
+	})
+	data, err := xml.Marshal(feed)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "encoding fail", "err", err)
+		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	ID         string         `xml:"id"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string       `xml:"title"`
+	Links   []atomLink   `xml:"link"`
+	ID      string       `xml:"id"`
+	Updated string       `xml:"updated"`
+	Author  atomAuthor   `xml:"author"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+// newAtomFeed returns the Atom feed of the articles of user id, self is
+// the URL of the feed, home the URL of the articles and link returns the
+// URL of an article.
+func newAtomFeed(id, self, home string, articles []*article, link func(a *article) string) *atomFeed {
+	updated := lastUpdate(articles)
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed := &atomFeed{
+		Title: "Articles of " + id,
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: self},
+			{Rel: "alternate", Href: home},
+		},
+		// The URL of the feed is its ID.
+		ID:      self,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: id},
+	}
+	for _, a := range articles {
+		entry := &atomEntry{
+			Title:     a.Title,
+			Links:     []atomLink{{Rel: "alternate", Type: htmlType, Href: link(a)}},
+			ID:        "urn:uuid:" + a.UUID,
+			Published: a.Timestamp.UTC().Format(time.RFC3339),
+			Updated:   a.lastChange().UTC().Format(time.RFC3339),
+			Content:   atomText{Type: "html", Value: string(renderMarkdown(a.Content))},
+		}
+		if a.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Value: a.Summary}
+		}
+		for _, t := range a.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{t})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// atomFeedHandler sends the latest published articles of an user as an
+// Atom feed.
+func (s *server) atomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	limit, err := parseFeedLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	articles, err := latestArticles(s.storeOf(r), id, limit)
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	self := baseURL(r) + "/feed/" + url.PathEscape(id) + "/atom.xml"
+	home := baseURL(r) + "/articles/" + url.PathEscape(id) + "/"
+	feed := newAtomFeed(id, self, home, articles, func(a *article) string {
+		return articleURL(r, id, a)
+	})
+	data, err := xml.Marshal(feed)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "encoding fail", "err", err)
+		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []*jsonFeedItem  `json:"items"`
+}
+
+// jsonFeedHandler sends the latest published articles of an user as a
+// JSON Feed 1.1.
+func (s *server) jsonFeedHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	limit, err := parseFeedLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	articles, err := latestArticles(s.storeOf(r), id, limit)
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	feed := &jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       "Articles of " + id,
+		HomePageURL: baseURL(r) + "/articles/" + url.PathEscape(id) + "/",
+		FeedURL:     baseURL(r) + "/feed/" + url.PathEscape(id) + "/feed.json",
+		Description: "The latest articles of " + id,
+		Authors:     []jsonFeedAuthor{{Name: id}},
+		Items:       []*jsonFeedItem{},
+	}
+	for _, a := range articles {
+		item := &jsonFeedItem{
+			ID:            a.UUID,
+			URL:           articleURL(r, id, a),
+			Title:         a.Title,
+			ContentHTML:   string(renderMarkdown(a.Content)),
+			Summary:       a.Summary,
+			DatePublished: a.Timestamp.UTC().Format(time.RFC3339),
+			Tags:          a.Tags,
+		}
+		if a.Updated != nil {
+			item.DateModified = a.Updated.UTC().Format(time.RFC3339)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	data, err := marshalJSON(feed)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "encoding fail", "err", err)
+		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	w.Write(data)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// articleFields maps the JSON names of the article fields to their
+// index in the struct.
+var articleFields = jsonFields(reflect.TypeOf(article{}))
+
+func jsonFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// parseFields reads the fields query parameter, a comma separated list
+// of article fields. It returns nil when all the fields are wanted.
+func parseFields(r *http.Request) ([]string, error) {
+	v := r.URL.Query().Get("fields")
+	if v == "" {
+		return nil, nil
+	}
+	fields := strings.Split(v, ",")
+	for _, f := range fields {
+		if _, ok := articleFields[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return fields, nil
+}
+
+// selectFields returns a copy of a where only the given fields are
+// set. It returns a when fields is nil.
+func selectFields(a *article, fields []string) *article {
+	if fields == nil {
+		return a
+	}
+	src := reflect.ValueOf(a).Elem()
+	dst := &article{}
+	v := reflect.ValueOf(dst).Elem()
+	for _, f := range fields {
+		i := articleFields[f]
+		v.Field(i).Set(src.Field(i))
+	}
+	return dst
+}
+
+// fieldsMap returns the given fields of a, keyed by their JSON name.
+// Unlike selectFields, the fields left out are absent from the JSON
+// encoding of the result, instead of being set to their zero value.
+func fieldsMap(a *article, fields []string) map[string]interface{} {
+	v := reflect.ValueOf(a).Elem()
+	m := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		m[f] = v.Field(articleFields[f]).Interface()
+	}
+	return m
+}
+
+// articleMap returns the fields of a keyed by their JSON name, all the
+// fields set when fields is nil.
+func articleMap(a *article, fields []string) map[string]interface{} {
+	if fields != nil {
+		return fieldsMap(a, fields)
+	}
+	// The JSON encoding leaves out the empty optional fields.
+	var m map[string]interface{}
+	data, _ := json.Marshal(a)
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// jsonArticle returns the value to encode as JSON for a, restricted to
+// the given fields unless fields is nil.
+func jsonArticle(a *article, fields []string) interface{} {
+	if fields == nil {
+		return a
+	}
+	return fieldsMap(a, fields)
+}
+
+// jsonArticles is like jsonArticle for a list of articles.
+func jsonArticles(articles []*article, fields []string) interface{} {
+	if fields == nil {
+		return articles
+	}
+	list := make([]map[string]interface{}, len(articles))
+	for i, a := range articles {
+		list[i] = fieldsMap(a, fields)
+	}
+	return list
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// versionFlag prints the version instead of serving.
+var versionFlag = flag.Bool("version", false, "print the version and exit")
+
+// variableFlags are the flags setting a variable, they override the
+// environment and the configuration file.
+var variableFlags = []struct {
+	name, key, usage string
+}{
+	{"config", "BLOG_API_CONFIG", "configuration `file`, overrides BLOG_API_CONFIG"},
+	{"addr", "BLOG_API_ADDR", "`address` to listen on, overrides BLOG_API_ADDR (default \":8080\")"},
+	{"db", "BLOG_API_DB", "`path` of the Bolt database, overrides BLOG_API_DB (default \"blog.db\")"},
+}
+
+func init() {
+	for _, f := range variableFlags {
+		flag.String(f.name, "", f.usage)
+	}
+}
+
+// flagSettings returns the variables set by the flags given on the
+// command line.
+func flagSettings() map[string]string {
+	vars := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		for _, v := range variableFlags {
+			if v.name == f.Name {
+				vars[v.key] = f.Value.String()
+			}
+		}
+	})
+	return vars
+}
+
+// usage prints the help of the command line.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintf(out, "Commands:\n")
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.usage, cmd.help)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n")
+	fmt.Fprintf(out, "The settings are read from the BLOG_API_* environment variables, then from\n")
+	fmt.Fprintf(out, "the configuration file, see the README.\n\n")
+	fmt.Fprintf(out, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+// printVersion prints the build information of the server.
+func printVersion() {
+	b := currentBuild()
+	fmt.Printf("blog-api %s\n", b.Version)
+	if b.Commit != "" {
+		fmt.Printf("commit %s\n", b.Commit)
+	}
+	if b.BuildDate != "" {
+		fmt.Printf("built %s\n", b.BuildDate)
+	}
+	fmt.Printf("%s\n", b.GoVersion)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// indexDoc is the document indexed for an article.
+type indexDoc struct {
+	User      string    `json:"user"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// searchIndex is a persistent full-text index of the articles. The
+// documents are keyed by user ID and article UUID.
+type searchIndex struct {
+	index bleve.Index
+}
+
+// newIndexMapping returns the mapping of the index documents: titles
+// and contents are analyzed as English, with stemming.
+func newIndexMapping() *mapping.IndexMappingImpl {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = en.AnalyzerName
+	user := bleve.NewTextFieldMapping()
+	user.Analyzer = keyword.Name
+	user.Store = false
+	timestamp := bleve.NewDateTimeFieldMapping()
+	timestamp.Store = false
+
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("user", user)
+	doc.AddFieldMappingsAt("title", text)
+	doc.AddFieldMappingsAt("content", text)
+	doc.AddFieldMappingsAt("timestamp", timestamp)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	return m
+}
+
+// openSearchIndex opens the index at path. A missing index is created
+// and filled with the articles of store, unless readOnly is set.
+func openSearchIndex(path string, store Store, readOnly bool) (*searchIndex, error) {
+	index, err := bleve.OpenUsing(path, map[string]interface{}{
+		"read_only": readOnly,
+	})
+	if err != bleve.ErrorIndexPathDoesNotExist || readOnly {
+		if err != nil {
+			return nil, err
+		}
+		return &searchIndex{index: index}, nil
+	}
+	index, err = bleve.New(path, newIndexMapping())
+	if err != nil {
+		return nil, err
+	}
+	idx := &searchIndex{index: index}
+	n, err := idx.reindex(store)
+	if err != nil {
+		index.Close()
+		os.RemoveAll(path)
+		return nil, err
+	}
+	slog.Info("indexed articles", "count", n)
+	return idx, nil
+}
+
+func docID(id, uuid string) string {
+	return id + "/" + uuid
+}
+
+// add indexes or reindexes the articles of user id.
+func (idx *searchIndex) add(id string, articles ...*article) error {
+	b := idx.index.NewBatch()
+	for _, a := range articles {
+		err := b.Index(docID(id, a.UUID), &indexDoc{
+			User:      id,
+			Title:     a.Title,
+			Content:   a.Content,
+			Timestamp: a.Timestamp,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return idx.index.Batch(b)
+}
+
+// remove removes the articles of user id with the given UUIDs.
+func (idx *searchIndex) remove(id string, uuids ...string) error {
+	b := idx.index.NewBatch()
+	for _, uuid := range uuids {
+		b.Delete(docID(id, uuid))
+	}
+	return idx.index.Batch(b)
+}
+
+// clear removes all the articles from the index.
+func (idx *searchIndex) clear() error {
+	for {
+		req := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), importBatchSize, 0, false)
+		res, err := idx.index.Search(req)
+		if err != nil {
+			return err
+		}
+		if len(res.Hits) == 0 {
+			return nil
+		}
+		b := idx.index.NewBatch()
+		for _, h := range res.Hits {
+			b.Delete(h.ID)
+		}
+		err = idx.index.Batch(b)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// reindex indexes all the articles of store, it returns the number of
+// articles indexed.
+func (idx *searchIndex) reindex(store Store) (int, error) {
+	users, err := store.Users()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range users {
+		articles, err := store.List(id)
+		if err == errUnknownID {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		for len(articles) > 0 {
+			batch := articles
+			if len(batch) > importBatchSize {
+				batch = batch[:importBatchSize]
+			}
+			err = idx.add(id, batch...)
+			if err != nil {
+				return n, err
+			}
+			articles = articles[len(batch):]
+			n += len(batch)
+		}
+	}
+	return n, nil
+}
+
+// searchHit is an article found in the index.
+type searchHit struct {
+	UUID string
+	// Fragments holds the highlighted snippets of the article, by
+	// field.
+	Fragments map[string][]string
+}
+
+// search runs the query string text on the articles of user id created
+// within the time range of q, most relevant first. It returns the page
+// of hits selected by q and the total number of hits. The query string
+// supports phrases in double quotes and words prefixed with + or -.
+func (idx *searchIndex) search(id, text string, q *listQuery) ([]*searchHit, int, error) {
+	user := bleve.NewTermQuery(id)
+	user.SetField("user")
+	queries := []query.Query{user, bleve.NewQueryStringQuery(text)}
+	if !q.since.IsZero() || !q.until.IsZero() {
+		// A zero time leaves the range open.
+		r := bleve.NewDateRangeQuery(q.since, q.until)
+		r.SetField("timestamp")
+		queries = append(queries, r)
+	}
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(queries...), q.limit, q.offset, false)
+	req.Highlight = bleve.NewHighlightWithStyle("html")
+	req.Highlight.AddField("title")
+	req.Highlight.AddField("content")
+	res, err := idx.index.Search(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	hits := make([]*searchHit, len(res.Hits))
+	for i, h := range res.Hits {
+		// The fields without any match have a fragment too.
+		for field, fragments := range h.Fragments {
+			if !strings.Contains(strings.Join(fragments, ""), "<mark>") {
+				delete(h.Fragments, field)
+			}
+		}
+		hits[i] = &searchHit{
+			UUID:      strings.TrimPrefix(h.ID, id+"/"),
+			Fragments: h.Fragments,
+		}
+	}
+	return hits, int(res.Total), nil
+}
+
+func (idx *searchIndex) Close() error {
+	return idx.index.Close()
+}
+
+// indexedStore is a Store keeping a search index up to date. The
+// articles are indexed once written to the store, an indexing failure
+// is logged but does not fail the write.
+type indexedStore struct {
+	Store
+	index *searchIndex
+}
+
+// baseStore returns the store wrapped by st, if any. The optional
+// interfaces of the stores must be checked on the base store.
+func baseStore(st Store) Store {
+	if s, ok := st.(*indexedStore); ok {
+		return s.Store
+	}
+	return st
+}
+
+func logIndexError(err error) {
+	if err != nil {
+		slog.Error("fail to index articles", "err", err)
+	}
+}
+
+func (s *indexedStore) Put(id string, a *article) error {
+	err := s.Store.Put(id, a)
+	if err == nil {
+		logIndexError(s.index.add(id, a))
+	}
+	return err
+}
+
+func (s *indexedStore) PutAll(id string, articles []*article) error {
+	err := s.Store.PutAll(id, articles)
+	if err == nil {
+		logIndexError(s.index.add(id, articles...))
+	}
+	return err
+}
+
+func (s *indexedStore) Update(id, title string, fn func(a *article) error) (*article, error) {
+	a, err := s.Store.Update(id, title, fn)
+	if err == nil {
+		logIndexError(s.index.add(id, a))
+	}
+	return a, err
+}
+
+func (s *indexedStore) Delete(id, title string, check func(a *article) error) error {
+	var uuid string
+	err := s.Store.Delete(id, title, func(a *article) error {
+		uuid = a.UUID
+		if check != nil {
+			return check(a)
+		}
+		return nil
+	})
+	if err == nil {
+		logIndexError(s.index.remove(id, uuid))
+	}
+	return err
+}
+
+func (s *indexedStore) DeleteAll(id string) error {
+	articles, err := s.Store.List(id)
+	if err != nil {
+		return err
+	}
+	err = s.Store.DeleteAll(id)
+	if err == nil {
+		uuids := make([]string, len(articles))
+		for i, a := range articles {
+			uuids[i] = a.UUID
+		}
+		logIndexError(s.index.remove(id, uuids...))
+	}
+	return err
+}
+
+func (s *indexedStore) DeleteFunc(id string, match func(a *article) bool) (int, error) {
+	var uuids []string
+	n, err := s.Store.DeleteFunc(id, func(a *article) bool {
+		if !match(a) {
+			return false
+		}
+		uuids = append(uuids, a.UUID)
+		return true
+	})
+	if err == nil {
+		logIndexError(s.index.remove(id, uuids...))
+	}
+	return n, err
+}
+
+func (s *indexedStore) Restore(id, uuid string) error {
+	err := s.Store.Restore(id, uuid)
+	if err != nil {
+		return err
+	}
+	a, err := s.Store.GetByUUID(id, uuid)
+	if err == nil {
+		err = s.index.add(id, a)
+	}
+	logIndexError(err)
+	return nil
+}
+
+func (s *indexedStore) Close() error {
+	err := s.index.Close()
+	if err != nil {
+		s.Store.Close()
+		return err
+	}
+	return s.Store.Close()
+}
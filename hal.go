@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// halType is the media type of the HAL representation of the articles,
+// where the resources link to each other, see
+// https://tools.ietf.org/html/draft-kelly-json-hal.
+const halType = "application/hal+json"
+
+// halLink is a HAL link object.
+type halLink struct {
+	Href string `json:"href"`
+}
+
+// halArticle returns the HAL resource of the article a of user id sent in
+// response to r, the article fields with its links. The update link
+// accepts PUT and PATCH requests, the delete link DELETE requests.
+func halArticle(r *http.Request, id string, a *article, fields []string) map[string]interface{} {
+	self := linkPath(r, "/article/"+url.PathEscape(id)+"/"+url.PathEscape(a.Title)+"/")
+	m := articleMap(a, fields)
+	m["_links"] = map[string]halLink{
+		"self":       {self},
+		"collection": {linkPath(r, "/articles/"+url.PathEscape(id)+"/")},
+		"update":     {self},
+		"delete":     {self},
+	}
+	return m
+}
+
+// writeHALArticle writes a as a HAL resource.
+func writeHALArticle(w http.ResponseWriter, r *http.Request, a *article, fields []string) {
+	data, _ := marshalJSON(halArticle(r, mux.Vars(r)["id"], a, fields))
+	w.Header().Set("Content-Type", halType)
+	w.Write(data)
+}
+
+// halArticles returns the HAL resource of a page of articles listed by
+// q, the articles are embedded. The next link follows the cursors when
+// they are used, the offsets otherwise.
+func halArticles(r *http.Request, q *listQuery, articles []*article, total int, next string, fields []string) map[string]interface{} {
+	id := mux.Vars(r)["id"]
+	embedded := make([]map[string]interface{}, len(articles))
+	for i, a := range articles {
+		embedded[i] = halArticle(r, id, a, fields)
+	}
+	links := map[string]halLink{
+		"self": {linkPath(r, r.URL.RequestURI())},
+	}
+	page := func(key, value string) halLink {
+		u := *r.URL
+		values := u.Query()
+		values.Set(key, value)
+		if key == "cursor" {
+			values.Del("offset")
+		}
+		u.RawQuery = values.Encode()
+		return halLink{linkPath(r, u.RequestURI())}
+	}
+	m := map[string]interface{}{
+		"_embedded": map[string]interface{}{"articles": embedded},
+		"_links":    links,
+	}
+	if q.paged {
+		if next != "" {
+			links["next"] = page("cursor", next)
+		}
+		return m
+	}
+	m["total"] = total
+	if q.offset+q.limit < total {
+		links["next"] = page("offset", strconv.Itoa(q.offset+q.limit))
+	}
+	return m
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// checker is implemented by the stores able to check that they can
+// serve the requests.
+type checker interface {
+	// Check returns the result of each check of the store by name, a
+	// nil error when it passed.
+	Check() map[string]error
+}
+
+// healthStatus is the body of the health endpoints.
+type healthStatus struct {
+	// Status is either ok or unavailable.
+	Status string `json:"status"`
+	// Checks are the results of the checks, ok or the error.
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// writeHealth sends status as JSON, with 503 when it is not ok.
+func writeHealth(w http.ResponseWriter, status *healthStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(data)
+}
+
+// healthzHandler tells that the process is alive.
+func (s *server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, &healthStatus{Status: "ok"})
+}
+
+// readyzHandler tells whether the store can serve the requests, with
+// the result of each of its checks.
+func (s *server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	status := &healthStatus{Status: "ok"}
+	c, ok := baseStore(s.store).(checker)
+	if ok {
+		status.Checks = make(map[string]string)
+		for name, err := range c.Check() {
+			status.Checks[name] = "ok"
+			if err != nil {
+				status.Status = "unavailable"
+				status.Checks[name] = err.Error()
+			}
+		}
+	}
+	writeHealth(w, status)
+}
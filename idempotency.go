@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxIdempotencyKeyLength is the length of the longest Idempotency-Key.
+const maxIdempotencyKeyLength = 255
+
+// idempotentResponse is the response to a request with an
+// Idempotency-Key, sent again to the retries.
+type idempotentResponse struct {
+	// fingerprint is the hash of the body of the request, a retry must
+	// send the same body.
+	fingerprint string
+	// done is false while the request is served.
+	done    bool
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// idempotencyStore remembers the responses to the requests with an
+// Idempotency-Key for ttl.
+type idempotencyStore struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	nextSweep time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:       ttl,
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+// begin returns the response to the request with the key, or records
+// that it is being served and returns nil.
+func (s *idempotencyStore) begin(key, fingerprint string, now time.Time) *idempotentResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.nextSweep) {
+		for k, resp := range s.responses {
+			if now.After(resp.expires) {
+				delete(s.responses, k)
+			}
+		}
+		s.nextSweep = now.Add(time.Minute)
+	}
+	resp := s.responses[key]
+	if resp != nil && now.Before(resp.expires) {
+		return resp
+	}
+	s.responses[key] = &idempotentResponse{
+		fingerprint: fingerprint,
+		expires:     now.Add(s.ttl),
+	}
+	return nil
+}
+
+// end records the response to the request with the key, nil forgets
+// the request so that it can be retried.
+func (s *idempotencyStore) end(key string, resp *idempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp == nil {
+		delete(s.responses, key)
+		return
+	}
+	prev := s.responses[key]
+	if prev == nil {
+		return
+	}
+	resp.fingerprint = prev.fingerprint
+	resp.expires = prev.expires
+	resp.done = true
+	s.responses[key] = resp
+}
+
+// recordingWriter keeps a copy of the response written to w.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns w.ResponseWriter to http.ResponseController.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// idempotencyMiddleware serves once the POST requests with the same
+// Idempotency-Key from a client, the retries get the first response.
+// The server errors are not remembered, the request can be retried.
+func idempotencyMiddleware(h http.Handler, s *idempotencyStore, a *authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idemKey := r.Header.Get("Idempotency-Key")
+		if r.Method != "POST" || idemKey == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if len(idemKey) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key too long")
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "fail to read body")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		sum := sha256.Sum256(data)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		r, client := clientKey(r, a)
+		key := client + " " + r.URL.Path + " " + idemKey
+		resp := s.begin(key, fingerprint, time.Now())
+		switch {
+		case resp == nil:
+		case resp.fingerprint != fingerprint:
+			writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key used by another request")
+			return
+		case !resp.done:
+			writeError(w, http.StatusConflict, "request with the same Idempotency-Key in progress")
+			return
+		default:
+			// The headers set before, e.g. the rate limits, are kept.
+			for k, v := range resp.header {
+				if _, ok := w.Header()[k]; !ok {
+					w.Header()[k] = v
+				}
+			}
+			slog.DebugContext(r.Context(), "response replayed", "idempotency_key", idemKey)
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(resp.status)
+			w.Write(resp.body)
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: w}
+		served := false
+		defer func() {
+			if !served || rec.status >= 500 {
+				s.end(key, nil)
+			}
+		}()
+		h.ServeHTTP(rec, r)
+		served = true
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= 500 {
+			return
+		}
+		header := make(http.Header, len(w.Header()))
+		for k, v := range w.Header() {
+			header[k] = append([]string(nil), v...)
+		}
+		s.end(key, &idempotentResponse{
+			status: rec.status,
+			header: header,
+			body:   rec.body.Bytes(),
+		})
+	})
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ghodss/yaml"
+)
+
+// postNameRegexp matches the names of the posts of Jekyll, the date then
+// the title, e.g. 2024-03-01-hello-world.md.
+var postNameRegexp = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// frontMatterDates are the layouts of the dates of the front matter.
+var frontMatterDates = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// markdownExt reports whether path is a Markdown file.
+func markdownExt(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
+
+// splitFrontMatter returns the front matter of data, in YAML between ---
+// lines or in TOML between +++ lines, and the content following it.
+func splitFrontMatter(data []byte) (map[string]interface{}, []byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	var delim string
+	switch {
+	case bytes.HasPrefix(data, []byte("---\n")):
+		delim = "---"
+	case bytes.HasPrefix(data, []byte("+++\n")):
+		delim = "+++"
+	default:
+		return nil, data, nil
+	}
+	rest := data[len(delim)+1:]
+	if bytes.HasPrefix(rest, []byte(delim+"\n")) {
+		return nil, rest[len(delim)+1:], nil
+	}
+	var matter, content []byte
+	if end := bytes.Index(rest, []byte("\n"+delim+"\n")); end >= 0 {
+		matter, content = rest[:end], rest[end+len(delim)+2:]
+	} else if bytes.HasSuffix(rest, []byte("\n"+delim)) {
+		matter = rest[:len(rest)-len(delim)-1]
+	} else {
+		return nil, nil, errors.New("unterminated front matter")
+	}
+	var fm map[string]interface{}
+	var err error
+	if delim == "---" {
+		err = yaml.Unmarshal(matter, &fm)
+	} else {
+		err = toml.Unmarshal(matter, &fm)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("front matter: %v", err)
+	}
+	return fm, content, nil
+}
+
+// frontMatterString returns the string key of fm, empty when unset.
+func frontMatterString(fm map[string]interface{}, key string) (string, error) {
+	switch v := fm[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	}
+	return "", fmt.Errorf("%s is not a string", key)
+}
+
+// frontMatterDate returns the time of the date key of fm, the zero time
+// when unset.
+func frontMatterDate(fm map[string]interface{}) (time.Time, error) {
+	switch v := fm["date"].(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range frontMatterDates {
+			t, err := time.Parse(layout, strings.TrimSpace(v))
+			if err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %v", fm["date"])
+}
+
+// frontMatterTags returns the tags of fm, a list or a string of tags
+// separated by spaces or commas.
+func frontMatterTags(fm map[string]interface{}) ([]string, error) {
+	switch v := fm["tags"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(strings.Replace(v, ",", " ", -1)), nil
+	case []interface{}:
+		tags := make([]string, len(v))
+		for i, t := range v {
+			s, err := settingValue(t)
+			if err != nil {
+				return nil, fmt.Errorf("tags: %v", err)
+			}
+			tags[i] = s
+		}
+		return tags, nil
+	}
+	return nil, errors.New("tags is not a list")
+}
+
+// markdownArticle returns the article of the Markdown file path. The
+// title and the date default to the ones of the name of the file, the
+// date to its modification time otherwise.
+func markdownArticle(path string, now time.Time) (*article, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fm, content, err := splitFrontMatter(data)
+	if err != nil {
+		return nil, err
+	}
+	a := &article{Content: strings.TrimSpace(string(content))}
+	a.Title, err = frontMatterString(fm, "title")
+	if err == nil {
+		a.Slug, err = frontMatterString(fm, "slug")
+	}
+	if err == nil {
+		a.Summary, err = frontMatterString(fm, "description")
+	}
+	if err != nil {
+		return nil, err
+	}
+	t, err := frontMatterDate(fm)
+	if err != nil {
+		return nil, err
+	}
+	a.Tags, err = frontMatterTags(fm)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if name == "index" {
+		// A page bundle of Hugo, named after its directory.
+		name = filepath.Base(filepath.Dir(path))
+	}
+	if m := postNameRegexp.FindStringSubmatch(name); m != nil {
+		name = m[2]
+		if t.IsZero() {
+			t, _ = time.Parse("2006-01-02", m[1])
+		}
+	}
+	if a.Title == "" && name != "" {
+		title := []rune(strings.Replace(name, "-", " ", -1))
+		title[0] = unicode.ToUpper(title[0])
+		a.Title = string(title)
+	}
+	if t.IsZero() {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		t = fi.ModTime()
+	}
+
+	draft := fm["draft"] == true || filepath.Base(filepath.Dir(path)) == "_drafts"
+	if draft {
+		a.Status = statusDraft
+	}
+	slug := a.Slug
+	err = prepareArticle(a, now)
+	if err != nil {
+		return nil, err
+	}
+	a.Timestamp = t
+	// Only a canonical slug is kept, the store assigns one otherwise.
+	if slugify(slug) == slug {
+		a.Slug = slug
+	}
+	return a, nil
+}
+
+// markdownArticles returns the articles of the Markdown files of dir and
+// its subdirectories, the hidden ones and the section pages of Hugo are
+// skipped.
+func markdownArticles(dir string, now time.Time) ([]*article, error) {
+	var articles []*article
+	paths := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := fi.Name()
+		if path != dir && strings.HasPrefix(name, ".") {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() || !markdownExt(name) || strings.HasPrefix(name, "_index.") {
+			return nil
+		}
+		a, err := markdownArticle(path, now)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		// The titles identify the articles.
+		if other, ok := paths[a.Title]; ok {
+			return fmt.Errorf("%s: title %q of %s too", path, a.Title, other)
+		}
+		paths[a.Title] = path
+		articles = append(articles, a)
+		return nil
+	})
+	return articles, err
+}
+
+// importMarkdownCommand stores the Markdown files of the directory of
+// args as the articles of an user, replacing the ones with the same
+// title.
+func importMarkdownCommand(cfg *config, args []string) {
+	fs := flag.NewFlagSet("import-md", flag.ExitOnError)
+	id := fs.String("user", "", "ID of the user of the articles")
+	fs.Parse(args)
+	// The flags may follow the directory.
+	var dir string
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+		fs.Parse(fs.Args()[1:])
+	}
+	if fs.NArg() > 0 {
+		fatal("too many arguments", "command", "import-md", "args", fs.Args())
+	}
+	if dir == "" || *id == "" {
+		fatal("missing directory or -user")
+	}
+
+	articles, err := markdownArticles(dir, time.Now())
+	if err != nil {
+		fatal("fail to read Markdown files", "err", err)
+	}
+	if len(articles) == 0 {
+		fatal("no Markdown files in directory", "dir", dir)
+	}
+	store, _, err := openIndexedStore(cfg)
+	if err != nil {
+		fatal("fail to open DB", "err", err)
+	}
+	defer store.Close()
+	err = store.PutAll(*id, articles)
+	if err != nil {
+		fatal("fail to store articles", "err", err, "id", *id)
+	}
+	slog.Info("imported Markdown files", "id", *id, "count", len(articles))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ipFilter restricts the IPs of the clients.
+type ipFilter struct {
+	// allow are the networks of the clients, every client is allowed
+	// when empty.
+	allow []*net.IPNet
+	// deny are the networks of the rejected clients, even when allowed.
+	deny []*net.IPNet
+	// adminAllow are the networks allowed to call the admin endpoints
+	// and to delete, every allowed client can when empty.
+	adminAllow []*net.IPNet
+}
+
+// newIPFilter returns the filter configured by cfg, nil when nothing is
+// filtered.
+func newIPFilter(cfg *config) *ipFilter {
+	if len(cfg.IPAllow) == 0 && len(cfg.IPDeny) == 0 && len(cfg.AdminIPAllow) == 0 {
+		return nil
+	}
+	return &ipFilter{
+		allow:      cfg.IPAllow,
+		deny:       cfg.IPDeny,
+		adminAllow: cfg.AdminIPAllow,
+	}
+}
+
+// containsIP reports whether ip is in one of the networks.
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether the client of r can send it.
+func (f *ipFilter) allowed(r *http.Request) bool {
+	ip := net.ParseIP(clientIP(r))
+	if ip == nil {
+		return false
+	}
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	if len(f.allow) > 0 && !containsIP(f.allow, ip) {
+		return false
+	}
+	admin := adminPath(r.URL.Path) || r.Method == "DELETE"
+	if admin && len(f.adminAllow) > 0 && !containsIP(f.adminAllow, ip) {
+		return false
+	}
+	return true
+}
+
+// ipFilterMiddleware rejects with 403 the requests of the clients not
+// allowed by f.
+func ipFilterMiddleware(h http.Handler, f *ipFilter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.allowed(r) {
+			slog.DebugContext(r.Context(), "address not allowed", "remote_ip", clientIP(r))
+			writeError(w, http.StatusForbidden, "address not allowed")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// parseCIDRs parses the networks of list, an IP without mask is a
+// network of one address.
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", s)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
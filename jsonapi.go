@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// jsonAPIType is the media type of the JSON:API representation of the
+// articles, see https://jsonapi.org.
+const jsonAPIType = "application/vnd.api+json"
+
+// jsonAPIResource is a JSON:API resource object.
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id,omitempty"`
+	Attributes    map[string]interface{}         `json:"attributes,omitempty"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+// jsonAPIRelationship is a to-one relationship of a resource.
+type jsonAPIRelationship struct {
+	Data *jsonAPIResource `json:"data"`
+}
+
+// jsonAPIDocument is a JSON:API top level document, Data is a resource
+// or a list of resources.
+type jsonAPIDocument struct {
+	JSONAPI map[string]string `json:"jsonapi"`
+	Data    interface{}       `json:"data"`
+	Meta    map[string]int    `json:"meta,omitempty"`
+	Links   map[string]string `json:"links,omitempty"`
+}
+
+func newJSONAPIDocument(data interface{}) *jsonAPIDocument {
+	return &jsonAPIDocument{
+		JSONAPI: map[string]string{"version": "1.0"},
+		Data:    data,
+	}
+}
+
+// jsonAPIArticle returns the resource of the article a of user id sent in
+// response to r, restricted to the given fields unless fields is nil. The UUID is the
+// ID of the resource and the category a relationship.
+func jsonAPIArticle(r *http.Request, id string, a *article, fields []string) *jsonAPIResource {
+	attributes := articleMap(a, fields)
+	res := &jsonAPIResource{
+		Type:       "articles",
+		ID:         a.UUID,
+		Attributes: attributes,
+		Links: map[string]string{
+			"self": linkPath(r, "/article/"+url.PathEscape(id)+"/by-id/"+a.UUID+"/"),
+		},
+	}
+	_, hasCategory := attributes["category"]
+	if hasCategory && a.Category != "" {
+		res.Relationships = map[string]jsonAPIRelationship{
+			"category": {Data: &jsonAPIResource{Type: "categories", ID: a.Category}},
+		}
+	}
+	delete(attributes, "uuid")
+	delete(attributes, "category")
+	return res
+}
+
+// writeJSONAPIArticle writes a as a JSON:API document.
+func writeJSONAPIArticle(w http.ResponseWriter, r *http.Request, a *article, fields []string) {
+	doc := newJSONAPIDocument(jsonAPIArticle(r, mux.Vars(r)["id"], a, fields))
+	doc.Links = map[string]string{"self": linkPath(r, r.URL.RequestURI())}
+	data, _ := marshalJSON(doc)
+	w.Header().Set("Content-Type", jsonAPIType)
+	w.Write(data)
+}
+
+// jsonAPIArticles returns the JSON:API document of a page of articles
+// listed by q. The pagination links follow the cursors when they are
+// used, the offsets otherwise.
+func jsonAPIArticles(r *http.Request, q *listQuery, articles []*article, total int, next string, fields []string) *jsonAPIDocument {
+	id := mux.Vars(r)["id"]
+	resources := make([]*jsonAPIResource, len(articles))
+	for i, a := range articles {
+		resources[i] = jsonAPIArticle(r, id, a, fields)
+	}
+	doc := newJSONAPIDocument(resources)
+	doc.Links = map[string]string{"self": linkPath(r, r.URL.RequestURI())}
+	page := func(key, value string) string {
+		u := *r.URL
+		values := u.Query()
+		values.Set(key, value)
+		if key == "cursor" {
+			values.Del("offset")
+		}
+		u.RawQuery = values.Encode()
+		return linkPath(r, u.RequestURI())
+	}
+	if q.paged {
+		doc.Links["first"] = page("cursor", "")
+		if next != "" {
+			doc.Links["next"] = page("cursor", next)
+		}
+		return doc
+	}
+	doc.Meta = map[string]int{"total": total}
+	offset := func(n int) string {
+		return page("offset", strconv.Itoa(n))
+	}
+	doc.Links["first"] = offset(0)
+	if q.offset > 0 {
+		prev := q.offset - q.limit
+		if prev < 0 {
+			prev = 0
+		}
+		doc.Links["prev"] = offset(prev)
+	}
+	if q.offset+q.limit < total {
+		doc.Links["next"] = offset(q.offset + q.limit)
+	}
+	if total > 0 {
+		doc.Links["last"] = offset((total - 1) / q.limit * q.limit)
+	}
+	return doc
+}
+
+// unmarshalJSONAPIArticle decodes a JSON:API document holding an
+// article resource into a.
+func unmarshalJSONAPIArticle(data []byte, a *article) error {
+	var doc struct {
+		Data *struct {
+			Type          string          `json:"type"`
+			Attributes    json.RawMessage `json:"attributes"`
+			Relationships struct {
+				Category *jsonAPIRelationship `json:"category"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+	if doc.Data == nil || doc.Data.Type != "articles" {
+		return errors.New("missing articles resource")
+	}
+	if len(doc.Data.Attributes) > 0 {
+		err = json.Unmarshal(doc.Data.Attributes, a)
+		if err != nil {
+			return err
+		}
+	}
+	if c := doc.Data.Relationships.Category; c != nil && c.Data != nil {
+		a.Category = c.Data.ID
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // SHA-256 signatures
+	_ "crypto/sha512" // SHA-384 and SHA-512 signatures
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwtLeeway is the clock skew tolerated on the expiration and the
+// start of the tokens.
+const jwtLeeway = time.Minute
+
+var errInvalidToken = errors.New("invalid token")
+
+// jwtVerifier checks the JSON Web Tokens signed either with a shared
+// secret (HS256, HS384, HS512) or with the keys published at a JWKS URL
+// (RS256, RS384, RS512, ES256, ES384, ES512).
+type jwtVerifier struct {
+	secret []byte
+	jwks   *jwks
+	// issuer and audience are checked when not empty.
+	issuer   string
+	audience string
+}
+
+// jwtClaims are the claims of a token checked by the server.
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	Expires   int64       `json:"exp"`
+	NotBefore int64       `json:"nbf"`
+}
+
+// jwtAudience is the aud claim, either a string or a list.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*a = jwtAudience{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (a jwtAudience) contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// isJWT reports whether the credential s looks like a token rather than
+// an API key.
+func isJWT(s string) bool {
+	return strings.Count(s, ".") == 2
+}
+
+// verify checks the signature and the claims of token, and returns its
+// claims.
+func (v *jwtVerifier) verify(token string, now time.Time) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	err = v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], sig)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &jwtClaims{}
+	err = decodeSegment(parts[1], claims)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	switch {
+	case claims.Subject == "":
+		return nil, errors.New("invalid token: missing subject")
+	case claims.Expires != 0 && now.After(time.Unix(claims.Expires, 0).Add(jwtLeeway)):
+		return nil, errors.New("invalid token: expired")
+	case claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-jwtLeeway)):
+		return nil, errors.New("invalid token: not valid yet")
+	case v.issuer != "" && claims.Issuer != v.issuer:
+		return nil, errors.New("invalid token: wrong issuer")
+	case v.audience != "" && !claims.Audience.contains(v.audience):
+		return nil, errors.New("invalid token: wrong audience")
+	}
+	return claims, nil
+}
+
+// verifySignature checks the signature sig of signed with the algorithm
+// alg. The secret is only used by the HMAC algorithms and the JWKS by
+// the others, for a public key to never be used as a secret.
+func (v *jwtVerifier) verifySignature(alg, kid, signed string, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("invalid token: unsupported algorithm %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("invalid token: unsupported algorithm %q", alg)
+	}
+
+	if alg[:2] == "HS" {
+		if v.secret == nil {
+			return fmt.Errorf("invalid token: unsupported algorithm %q", alg)
+		}
+		mac := hmac.New(hash.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("invalid token: bad signature")
+		}
+		return nil
+	}
+	if v.jwks == nil || (alg[:2] != "RS" && alg[:2] != "ES") {
+		return fmt.Errorf("invalid token: unsupported algorithm %q", alg)
+	}
+	key, err := v.jwks.key(kid)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg[:2] == "RS" && rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		// The signature is r and s, each padded to the size of the curve.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if alg[:2] == "ES" && len(sig) == 2*size {
+			r := new(big.Int).SetBytes(sig[:size])
+			s := new(big.Int).SetBytes(sig[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return nil
+			}
+		}
+	}
+	return errors.New("invalid token: bad signature")
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+const (
+	// jwksMaxAge is how long the keys are used before being fetched
+	// again.
+	jwksMaxAge = time.Hour
+	// jwksMinInterval limits how often the keys are fetched when a
+	// token is signed by an unknown key.
+	jwksMinInterval = time.Minute
+)
+
+// jwks caches the public keys published at a JWKS URL.
+type jwks struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+func newJWKS(url string) *jwks {
+	return &jwks{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// key returns the key kid, the keys are fetched again when they are
+// old or when kid is unknown. A token without kid may use the only key
+// of the set.
+func (s *jwks) key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.lookup(kid)
+	age := time.Since(s.fetched)
+	if (!ok || age > jwksMaxAge) && age > jwksMinInterval {
+		keys, err := fetchJWKS(s.client, s.url)
+		if err != nil {
+			// The cached keys are used until the URL works again.
+			slog.Error("fail to fetch JWKS", "err", err)
+		} else {
+			s.keys = keys
+		}
+		s.fetched = time.Now()
+		k, ok = s.lookup(kid)
+	}
+	if !ok {
+		return nil, errors.New("invalid token: unknown key")
+	}
+	return k, nil
+}
+
+func (s *jwks) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+// fetchJWKS downloads the signing keys published at url, the keys of
+// unknown types are skipped.
+func fetchJWKS(client *http.Client, url string) (map[string]crypto.PublicKey, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err1 := decodeBigInt(k.N)
+			e, err2 := decodeBigInt(k.E)
+			if err1 != nil || err2 != nil || !e.IsInt64() {
+				return nil, fmt.Errorf("invalid RSA key %q", k.Kid)
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := decodeBigInt(k.X)
+			y, err2 := decodeBigInt(k.Y)
+			if err1 != nil || err2 != nil || !curve.IsOnCurve(x, y) {
+				return nil, fmt.Errorf("invalid EC key %q", k.Kid)
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid number")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unixPrefix starts the addresses of unix sockets, followed by the path
+// of the socket, e.g. unix:///run/blog-api.sock.
+const unixPrefix = "unix://"
+
+// unixSocketPath returns the path of the unix socket of addr, false when
+// addr is a TCP address.
+func unixSocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, unixPrefix), true
+}
+
+// listen listens on the socket passed by systemd when activated, else on
+// the address of cfg.
+func listen(cfg *config) (net.Listener, error) {
+	activated, err := activationListeners()
+	if err != nil {
+		return nil, err
+	}
+	switch len(activated) {
+	case 0:
+	case 1:
+		return activated[0], nil
+	default:
+		for _, ln := range activated {
+			ln.Close()
+		}
+		return nil, fmt.Errorf("systemd passed %d sockets, expected one", len(activated))
+	}
+	return listenAddr(cfg, cfg.Addr)
+}
+
+// listenAddr listens on addr. A unix socket is given the mode and the
+// group of cfg, it is removed when the listener is closed.
+func listenAddr(cfg *config, addr string) (net.Listener, error) {
+	path, ok := unixSocketPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	err := removeStaleSocket(path)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, cfg.SocketMode)
+	if err == nil && cfg.SocketGroup != "" {
+		err = chgrp(path, cfg.SocketGroup)
+	}
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket at path left by a server which did
+// not stop cleanly, it fails when a server still listens on it.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// chgrp changes the group of the file at path to group, a name or an ID.
+func chgrp(path, group string) error {
+	gid, err := strconv.Atoi(group)
+	if err != nil {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return err
+		}
+		gid, err = strconv.Atoi(g.Gid)
+		if err != nil {
+			return err
+		}
+	}
+	return os.Chown(path, -1, gid)
+}
+
+// adminOnlyPath reports whether path is only served by the admin listener
+// when one is set: the admin, debug and metrics endpoints.
+func adminOnlyPath(path string) bool {
+	return adminPath(path) || path == "/metrics"
+}
+
+// adminListenerPath reports whether path is served by the admin listener,
+// the health and version endpoints are for the probes.
+func adminListenerPath(path string) bool {
+	switch path {
+	case "/healthz", "/readyz", "/version":
+		return true
+	}
+	return adminOnlyPath(path)
+}
+
+// servePathsMiddleware answers 404 Not Found to the requests whose path is
+// not served.
+func servePathsMiddleware(h http.Handler, served func(path string) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !served(r.URL.Path) {
+			writeError(w, http.StatusNotFound, "nothing here...")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
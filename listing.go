@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultLimit is the number of articles listed when no limit is
+	// given.
+	defaultLimit = 100
+	// maxLimit is the maximum number of articles listed at once.
+	maxLimit = 1000
+)
+
+// listQuery holds the parameters of an articles listing.
+type listQuery struct {
+	order  order
+	offset int
+	limit  int
+	now    time.Time
+	// since and until restrict the listing to the articles created in
+	// [since, until) when set.
+	since time.Time
+	until time.Time
+	// tag restricts the listing to the articles with this tag when set.
+	tag string
+	// meta restricts the listing to the articles with all these
+	// metadata when set.
+	meta map[string]string
+	// category restricts the listing to the articles of this category
+	// and its subcategories when set.
+	category string
+	// status is the status of the articles listed, all the articles are
+	// listed when empty.
+	status string
+
+	// paged is set when the listing is paginated with cursors, after
+	// is then the walk key of the last article of the previous page.
+	paged bool
+	after []byte
+}
+
+// encodeCursor returns the opaque cursor pointing after the article a
+// in the order o.
+func encodeCursor(o order, a *article) string {
+	return base64.RawURLEncoding.EncodeToString(append([]byte{byte(o)}, walkKey(o, a)...))
+}
+
+// decodeCursor returns the walk key of a cursor produced by
+// encodeCursor for the order o.
+func decodeCursor(o order, cursor string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(data) == 0 || order(data[0]) != o {
+		return nil, errors.New("invalid cursor parameter")
+	}
+	return data[1:], nil
+}
+
+// parseListQuery reads the listing parameters from the URL query, sort
+// is the optional sort parameter of the URL path.
+func parseListQuery(r *http.Request, sort string) (*listQuery, error) {
+	q := &listQuery{
+		order: byTitle,
+		limit: defaultLimit,
+		now:   time.Now(),
+	}
+	switch sort {
+	case "":
+	case "asc":
+		q.order = byTimeAsc
+	case "desc":
+		q.order = byTimeDesc
+	default:
+		return nil, errors.New("invalid sort parameter")
+	}
+
+	values := r.URL.Query()
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			return nil, errors.New("invalid limit parameter")
+		}
+		q.limit = n
+	}
+	if v := values.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, errors.New("invalid offset parameter")
+		}
+		q.offset = n
+	}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"since", &q.since},
+		{"until", &q.until},
+	} {
+		v := values.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter", p.name)
+		}
+		*p.t = t
+	}
+	q.tag = strings.ToLower(strings.TrimSpace(values.Get("tag")))
+	for k, v := range values {
+		if !strings.HasPrefix(k, metaPrefix) {
+			continue
+		}
+		key := strings.TrimSpace(k[len(metaPrefix):])
+		if key == "" {
+			return nil, errors.New("invalid meta parameter")
+		}
+		if q.meta == nil {
+			q.meta = make(map[string]string)
+		}
+		q.meta[key] = v[0]
+	}
+	var err error
+	q.category, err = normalizeCategory(values.Get("category"))
+	if err != nil {
+		return nil, errors.New("invalid category parameter")
+	}
+	// Only the published articles are listed by default.
+	q.status = statusPublished
+	switch v := values.Get("status"); v {
+	case "":
+	case "all":
+		q.status = ""
+	default:
+		if checkStatus(v) != nil {
+			return nil, errors.New("invalid status parameter")
+		}
+		q.status = v
+	}
+	if cursor, ok := values["cursor"]; ok {
+		q.paged = true
+		if cursor[0] != "" {
+			var err error
+			q.after, err = decodeCursor(q.order, cursor[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return q, nil
+}
+
+// match reports whether a must be listed.
+func (q *listQuery) match(a *article) bool {
+	if !q.since.IsZero() && a.Timestamp.Before(q.since) {
+		return false
+	}
+	if !q.until.IsZero() && !a.Timestamp.Before(q.until) {
+		return false
+	}
+	if q.tag != "" && !a.hasTag(q.tag) {
+		return false
+	}
+	if !a.hasMeta(q.meta) {
+		return false
+	}
+	if q.category != "" && !a.inCategory(q.category) {
+		return false
+	}
+	if q.status != "" && a.published(q.now) != (q.status == statusPublished) {
+		return false
+	}
+	return !a.expired(q.now)
+}
+
+// done reports whether the articles following a in the walk cannot
+// match, which happens when walking past the time range.
+func (q *listQuery) done(a *article) bool {
+	switch q.order {
+	case byTimeAsc:
+		return !q.until.IsZero() && !a.Timestamp.Before(q.until)
+	case byTimeDesc:
+		return !q.since.IsZero() && a.Timestamp.Before(q.since)
+	}
+	return false
+}
+
+// list returns the page of articles of user id matching q, and the
+// total number of matching articles. With cursors, the total is not
+// computed and -1 is returned, next is then the cursor of the next page
+// or empty on the last page.
+func (q *listQuery) list(store Store, id string) (articles []*article, total int, next string, err error) {
+	skipped := 0
+	more := false
+	err = store.Walk(id, q.order, q.after, func(a *article) bool {
+		if q.done(a) {
+			return false
+		}
+		if !q.match(a) {
+			return true
+		}
+		total++
+		if skipped < q.offset {
+			skipped++
+			return true
+		}
+		if len(articles) == q.limit {
+			// Look for one more article to know if there is a next page.
+			more = true
+			return !q.paged
+		}
+		articles = append(articles, a)
+		return true
+	})
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if !q.paged {
+		return articles, total, "", nil
+	}
+	if more {
+		next = encodeCursor(q.order, articles[len(articles)-1])
+	}
+	return articles, -1, next, nil
+}
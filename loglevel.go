@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// logLevel is the level of the logs, changed at runtime by the admin.
+var logLevel = new(slog.LevelVar)
+
+// logLevelBody is the body of the log level endpoints.
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+// parseLogLevel parses a level among debug, info, warn and error.
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid level %q", s)
+}
+
+// levelName returns the name of level, as parsed by parseLogLevel.
+func levelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// setLogLevel changes the level of the logs and logs the change.
+func setLogLevel(level slog.Level, reason string) {
+	prev := logLevel.Level()
+	logLevel.Set(level)
+	// The change is logged even if the new level hides the infos.
+	slog.Warn("log level changed", "from", levelName(prev), "to", levelName(level), "by", reason)
+}
+
+// toggleDebugOnHangup switches the logs between the debug level and
+// level on each SIGHUP.
+func toggleDebugOnHangup(level slog.Level) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		if logLevel.Level() == slog.LevelDebug {
+			setLogLevel(level, "SIGHUP")
+		} else {
+			setLogLevel(slog.LevelDebug, "SIGHUP")
+		}
+	}
+}
+
+// getLogLevelHandler sends the level of the logs.
+func (s *server) getLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, &logLevelBody{levelName(logLevel.Level())})
+}
+
+// putLogLevelHandler changes the level of the logs, until the server
+// restarts.
+func (s *server) putLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var body logLevelBody
+	err := decodeRequest(r, &body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	level, err := parseLogLevel(body.Level)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	setLogLevel(level, "admin")
+	writeResponse(w, r, &logLevelBody{levelName(level)})
+}
@@ -2,108 +2,537 @@ package main
 
 import (
 	"bytes"
-	"encoding/gob"
-	"encoding/json"
+	"compress/gzip"
+	"context"
+	"crypto/cipher"
 	"encoding/xml"
 	"errors"
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-	"sort"
-	"strings"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
-	"github.com/boltdb/bolt"
+	"github.com/getsentry/sentry-go"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
-	"github.com/ulule/limiter"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var (
-	errUnknownID    = errors.New("unknown ID")
-	errUnknownTitle = errors.New("unknown title")
-)
-
-type article struct {
-	Title     string    `json:"title" xml:"title"`
-	Content   string    `json:"content" xml:"content"`
-	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
-}
-
 type server struct {
-	db  *bolt.DB
-	mux *mux.Router
+	store Store
+	// index is the full-text search index, search falls back to
+	// substring matching when nil.
+	index *searchIndex
+	mux   *mux.Router
+	// auth checks the credentials, nil when anyone can modify data.
+	auth *authenticator
+	// tracing is true when the requests are traced.
+	tracing bool
+	// maintenance rejects the requests of the clients while enabled.
+	maintenance maintenance
+	// views counts the views of the articles, nil when they are not
+	// counted.
+	views *viewCounter
 }
 
 func main() {
-	var err error
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-
-	addr := os.Getenv("BLOG_API_ADDR")
-	if addr == "" {
-		addr = ":8080"
+	flag.Usage = usage
+	flag.Parse()
+	if *versionFlag {
+		printVersion()
+		return
 	}
+	cfg, err := loadConfig(flagSettings())
+	if err != nil {
+		fatal("invalid configuration", "err", err)
+	}
+	logLevel.Set(cfg.LogLevel)
+	slog.SetDefault(newLogger(os.Stderr, cfg.LogFormat))
 
-	db := os.Getenv("BLOG_API_DB")
-	if db == "" {
-		db = "blog.db"
+	name, args := "serve", flag.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		fatal("unknown command", "command", name)
 	}
+	if cmd.maxArgs >= 0 && len(args) > cmd.maxArgs {
+		fatal("too many arguments", "command", name, "args", args)
+	}
+	cmd.run(cfg, args)
+}
 
+// serveCommand serves the API until interrupted.
+func serveCommand(cfg *config, args []string) {
 	srv := &server{}
-	srv.db, err = bolt.Open(db, 0666, nil)
+	var err error
+	srv.store, srv.index, err = openIndexedStore(cfg)
 	if err != nil {
-		log.Fatal(err)
+		fatal("fail to open DB", "err", err)
 	}
 
-	store := limiter.NewMemoryStore()
-	limit := limiter.NewLimiter(store, limiter.Rate{
-		Period: 1 * time.Minute,
-		Limit:  int64(264),
-	})
-	httpLimit := limiter.NewHTTPMiddleware(limit)
+	bg := newJobs()
+	if cfg.BackupDir != "" {
+		b, ok := baseStore(srv.store).(backuper)
+		if !ok {
+			fatal("backup not supported by the store")
+		}
+		schedule, err := parseCron(cfg.BackupSchedule)
+		if err != nil {
+			fatal("invalid backup schedule", "err", err)
+		}
+		scheduler := &backupScheduler{
+			store:    b,
+			dir:      cfg.BackupDir,
+			schedule: schedule,
+			keep:     cfg.BackupKeep,
+		}
+		bg.start(scheduler.run)
+	}
+
+	if !cfg.ReadOnly {
+		bg.start(func(stop <-chan struct{}) { purgeTrash(srv.store, cfg.TrashRetention, stop) })
+		bg.start(func(stop <-chan struct{}) { reapExpired(srv.store, stop) })
+		bg.start(func(stop <-chan struct{}) { publishScheduled(srv.store, stop) })
+		srv.views = newViewCounter(srv.store)
+		if srv.views != nil {
+			bg.start(srv.views.run)
+		}
+	}
+
+	srv.auth, err = newAuthenticator(cfg, srv.store)
+	if err != nil {
+		fatal("fail to set up authentication", "err", err)
+	}
+	if srv.auth == nil {
+		slog.Warn("AUTHENTICATION IS OFF: anyone can modify the articles, set BLOG_API_ADMIN_KEY or create an API key with the keys command and restart")
+	}
 
 	srv.mux = mux.NewRouter()
 	srv.mux.HandleFunc("/", srv.notFoundHandler)
 	// Article handlers.
 	srv.mux.HandleFunc("/article/{id}/{title}/", srv.getArticleHandler).Methods("GET")
+	srv.mux.HandleFunc("/article/{id}/{title}/", srv.putArticleHandler).Methods("PUT")
+	srv.mux.HandleFunc("/article/{id}/{title}/", srv.patchArticleHandler).Methods("PATCH")
 	srv.mux.HandleFunc("/article/{id}/{title}/", srv.deleteArticleHandler).Methods("DELETE")
+	srv.mux.HandleFunc("/article/{id}/{title}/rename", srv.renameArticleHandler).Methods("POST")
+	srv.mux.HandleFunc("/article/{id}/{title}/publish", srv.publishArticleHandler).Methods("POST")
+	srv.mux.HandleFunc("/article/{id}/{title}/related", srv.relatedArticlesHandler).Methods("GET")
+	srv.mux.HandleFunc("/article/{id}/{title}/html", srv.getArticleHTMLHandler).Methods("GET")
+	srv.mux.HandleFunc("/article/{id}/{title}/translations", srv.getTranslationsHandler).Methods("GET")
+	srv.mux.HandleFunc("/article/{id}/{title}/translations/{lang}", srv.putTranslationHandler).Methods("PUT")
+	srv.mux.HandleFunc("/article/{id}/{title}/translations/{lang}", srv.deleteTranslationHandler).Methods("DELETE")
+	srv.mux.HandleFunc("/article/{id}/{title}/like", srv.reactionsHandler).Methods("POST", "DELETE")
+	srv.mux.HandleFunc("/article/{id}/{title}/reactions", srv.reactionsHandler).Methods("GET")
+	srv.mux.HandleFunc("/article/{id}/{title}/reactions/{reaction}", srv.reactionsHandler).Methods("POST", "DELETE")
 	srv.mux.HandleFunc("/article/{id}/", srv.postArticleHandler).Methods("POST")
+	srv.mux.HandleFunc("/article/{id}/by-id/{uuid}/", srv.getArticleByUUIDHandler).Methods("GET")
+	srv.mux.HandleFunc("/article/{id}/by-id/{uuid}/", srv.deleteArticleByUUIDHandler).Methods("DELETE")
+	srv.mux.HandleFunc("/article/{id}/slug/{slug}/", srv.getArticleBySlugHandler).Methods("GET")
 	// Articles handlers.
 	srv.mux.HandleFunc("/articles/{id}/", srv.getArticlesHandler).Methods("GET")
+	srv.mux.HandleFunc("/articles/{id}/stats", srv.statsHandler).Methods("GET")
+	srv.mux.HandleFunc("/articles/{id}/search", srv.searchHandler).Methods("GET")
+	srv.mux.HandleFunc("/articles/{id}/tags", srv.getTagsHandler).Methods("GET")
+	srv.mux.HandleFunc("/articles/{id}/archive", srv.getArchiveHandler).Methods("GET")
+	srv.mux.HandleFunc("/articles/{id}/archive/{year}/{month}", srv.getArchiveMonthHandler).Methods("GET")
+	srv.mux.HandleFunc("/articles/{id}/popular", srv.popularArticlesHandler).Methods("GET")
 	srv.mux.HandleFunc("/articles/{id}/{sort}", srv.getArticlesHandler).Methods("GET")
 	srv.mux.HandleFunc("/articles/{id}/", srv.deleteArticlesHandler).Methods("DELETE")
-	h := httpLimit.Handler(srv.mux)
-	h = corsMiddleware(h)
-	h = handlers.LoggingHandler(os.Stdout, h)
+	srv.mux.HandleFunc("/articles/{id}/batch", srv.batchArticlesHandler).Methods("POST")
+	// Category handlers.
+	srv.mux.HandleFunc("/categories/{id}/", srv.getCategoriesHandler).Methods("GET")
+	srv.mux.HandleFunc("/categories/{id}/", srv.postCategoryHandler).Methods("POST")
+	srv.mux.HandleFunc("/categories/{id}/{path:.+}", srv.deleteCategoryHandler).Methods("DELETE")
+	// Feed handlers.
+	srv.mux.HandleFunc("/feed/{id}/rss.xml", srv.rssFeedHandler).Methods("GET")
+	srv.mux.HandleFunc("/feed/{id}/atom.xml", srv.atomFeedHandler).Methods("GET")
+	srv.mux.HandleFunc("/feed/{id}/feed.json", srv.jsonFeedHandler).Methods("GET")
+	// Blog handlers.
+	srv.mux.HandleFunc("/blog/{id}/", srv.getBlogHandler).Methods("GET")
+	srv.mux.HandleFunc("/blog/{id}/{slug}", srv.getBlogArticleHandler).Methods("GET")
+	// Trash handlers.
+	srv.mux.HandleFunc("/trash/{id}/", srv.getTrashHandler).Methods("GET")
+	srv.mux.HandleFunc("/trash/{id}/{title}/restore", srv.restoreArticleHandler).Methods("POST")
+	srv.mux.HandleFunc("/trash/{id}/by-id/{uuid}/restore", srv.restoreArticleByUUIDHandler).Methods("POST")
+	// Admin handlers.
+	srv.mux.HandleFunc("/admin/backup", srv.backupHandler).Methods("GET")
+	srv.mux.HandleFunc("/admin/restore", srv.restoreHandler).Methods("POST")
+	srv.mux.HandleFunc("/admin/compact", srv.compactHandler).Methods("POST")
+	srv.mux.HandleFunc("/admin/export", srv.exportHandler).Methods("GET")
+	srv.mux.HandleFunc("/admin/import", srv.importHandler).Methods("POST")
+	srv.mux.HandleFunc("/admin/verify", srv.verifyHandler).Methods("POST")
+	srv.mux.HandleFunc("/admin/dbstats", srv.dbStatsHandler).Methods("GET")
+	srv.mux.HandleFunc("/admin/keys", srv.getKeysHandler).Methods("GET")
+	srv.mux.HandleFunc("/admin/keys", srv.postKeyHandler).Methods("POST")
+	srv.mux.HandleFunc("/admin/keys/{key}", srv.deleteKeyHandler).Methods("DELETE")
+	srv.mux.HandleFunc("/admin/loglevel", srv.getLogLevelHandler).Methods("GET")
+	srv.mux.HandleFunc("/admin/loglevel", srv.putLogLevelHandler).Methods("PUT")
+	srv.mux.HandleFunc("/admin/maintenance", srv.getMaintenanceHandler).Methods("GET")
+	srv.mux.HandleFunc("/admin/maintenance", srv.postMaintenanceHandler).Methods("POST")
+	// Session handlers.
+	srv.mux.HandleFunc("/login", srv.getLoginHandler).Methods("GET")
+	srv.mux.HandleFunc("/login", srv.loginHandler).Methods("POST")
+	srv.mux.HandleFunc("/logout", srv.logoutHandler).Methods("POST")
+	// Health handlers.
+	srv.mux.HandleFunc("/healthz", srv.healthzHandler).Methods("GET")
+	srv.mux.HandleFunc("/readyz", srv.readyzHandler).Methods("GET")
+	srv.mux.HandleFunc("/version", srv.versionHandler).Methods("GET")
+	srv.mux.Handle("/metrics", newMetricsHandler(srv.store)).Methods("GET")
+	// API description.
+	srv.mux.HandleFunc("/openapi.json", srv.openAPIHandler).Methods("GET")
+	srv.mux.HandleFunc("/docs", srv.docsHandler).Methods("GET")
+	srv.mux.HandleFunc("/docs/", srv.docsHandler).Methods("GET")
+	// Debug handlers, only served behind the admin key.
+	if srv.auth != nil {
+		handleDebug(srv.mux)
+	}
+	var h http.Handler = srv.mux
+	if cfg.AdminAddr != "" {
+		h = servePathsMiddleware(h, func(path string) bool { return !adminOnlyPath(path) })
+	}
+	if cfg.ReadOnly {
+		h = readOnlyMiddleware(h)
+	}
+	if cfg.IdempotencyTTL > 0 {
+		h = idempotencyMiddleware(h, newIdempotencyStore(cfg.IdempotencyTTL), srv.auth)
+	}
+	if srv.auth != nil {
+		srv.auth.router = srv.mux
+		srv.auth.adminMetrics = cfg.AdminAddr == ""
+		h = authMiddleware(h, srv.auth)
+	} else {
+		h = noAuthMiddleware(h)
+	}
+	h = prettyMiddleware(h)
+	if cfg.MaxBodySize > 0 {
+		h = bodyLimitMiddleware(h, cfg.MaxBodySize)
+	}
+	h = rateLimitMiddleware(h, newRateLimits(cfg, srv.auth))
+	// The rejected clients do not count for the rate limits.
+	if f := newIPFilter(cfg); f != nil {
+		h = ipFilterMiddleware(h, f)
+	}
+	// The requests rejected during maintenance are not remembered by the
+	// idempotency and do not count for the rate limits.
+	h = maintenanceMiddleware(h, &srv.maintenance)
+	h = corsMiddleware(h, &cfg.CORS)
+	if cfg.SentryDSN != "" {
+		err = setupReporting(cfg)
+		if err != nil {
+			fatal("fail to set up error reporting", "err", err)
+		}
+		h = reportMiddleware(h)
+	}
+	// The panics are recovered inside the compression, which would
+	// start the response while unwinding.
+	h = recoverMiddleware(h)
+	if cfg.CompressLevel != gzip.NoCompression {
+		h = handlers.CompressHandlerLevel(h, cfg.CompressLevel)
+	}
+	shutdownTracing := func(context.Context) error { return nil }
+	if cfg.OTLPEndpoint != "" {
+		shutdownTracing, err = setupTracing(cfg)
+		if err != nil {
+			fatal("fail to set up tracing", "err", err)
+		}
+		srv.tracing = true
+		h = tracingMiddleware(h, srv.mux)
+	}
+	if cfg.SlowRequest > 0 {
+		h = slowRequestMiddleware(h, srv.mux, cfg.SlowRequest)
+	}
+	accessLogger := slog.Default()
+	accessLog, err := openAccessLog(cfg)
+	if err != nil {
+		fatal("fail to open access log", "err", err)
+	}
+	if accessLog != nil {
+		accessLogger = newLogger(accessLog, cfg.LogFormat)
+		if l, ok := accessLog.(*lumberjack.Logger); ok && cfg.AccessLogRotation > 0 {
+			bg.start(func(stop <-chan struct{}) { rotateAccessLog(l, cfg.AccessLogRotation, stop) })
+		}
+	}
+	if cfg.BasePath != "" {
+		h = basePathMiddleware(h, cfg.BasePath)
+	}
+	h = accessLogMiddleware(h, accessLogger)
+	h = requestIDMiddleware(h)
+	if cfg.TrustedProxies != nil {
+		h = proxyMiddleware(h, cfg.TrustedProxies)
+	}
+
+	if cfg.DebugAddr != "" {
+		go func() {
+			slog.Info("serving debug endpoints", "addr", cfg.DebugAddr)
+			fatal("fail to serve debug endpoints", "err", serveDebug(cfg))
+		}()
+	}
+
+	servers := []*http.Server{newHTTPServer(cfg, cfg.Addr, h)}
+	hs := servers[0]
+	if cfg.TLSCert != "" || len(cfg.ACMEDomains) > 0 {
+		hs.TLSConfig, err = newTLSConfig(cfg)
+		if err != nil {
+			fatal("invalid TLS configuration", "err", err)
+		}
+		if len(cfg.ACMEDomains) > 0 {
+			m := newACMEManager(cfg)
+			useACME(hs.TLSConfig, m)
+			go func() {
+				slog.Info("redirecting to HTTPS", "addr", cfg.ACMEHTTPAddr)
+				fatal("fail to redirect to HTTPS", "err", newHTTPServer(cfg, cfg.ACMEHTTPAddr, m.HTTPHandler(nil)).ListenAndServe())
+			}()
+		}
+	}
+	ln, err := listen(cfg)
+	if err != nil {
+		fatal("fail to listen", "err", err)
+	}
+	errc := make(chan error, 2)
+	if cfg.AdminAddr != "" {
+		admin := newHTTPServer(cfg, cfg.AdminAddr, srv.adminHandler(cfg, accessLogger))
+		servers = append(servers, admin)
+		adminLn, err := listenAddr(cfg, cfg.AdminAddr)
+		if err != nil {
+			fatal("fail to listen", "err", err)
+		}
+		go func() {
+			slog.Info("serving admin endpoints", "addr", adminLn.Addr().String())
+			errc <- admin.Serve(adminLn)
+		}()
+	}
+	go func() {
+		if hs.TLSConfig != nil {
+			slog.Info("listening with TLS", "addr", ln.Addr().String())
+			errc <- hs.ServeTLS(ln, cfg.TLSCert, cfg.TLSKey)
+			return
+		}
+		slog.Info("listening", "addr", ln.Addr().String())
+		errc <- hs.Serve(ln)
+	}()
+
+	go toggleDebugOnHangup(cfg.LogLevel)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		fatal("fail to serve", "err", err)
+	case s := <-sig:
+		// A second signal kills the server.
+		signal.Stop(sig)
+		slog.Info("shutting down", "signal", s.String())
+	}
+
+	// The requests in flight are given the timeout to complete, the
+	// store waits for the transactions of the remaining ones on close.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	for _, hs := range servers {
+		err = hs.Shutdown(ctx)
+		if err != nil {
+			slog.Warn("requests interrupted", "err", err)
+			hs.Close()
+		}
+	}
+	bg.shutdown()
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancelFlush()
+	err = shutdownTracing(flushCtx)
+	if err != nil {
+		slog.Error("fail to send traces", "err", err)
+	}
+	if cfg.SentryDSN != "" {
+		sentry.Flush(flushTimeout)
+	}
+	if accessLog != nil {
+		accessLog.Close()
+	}
+	err = srv.store.Close()
+	if err != nil {
+		fatal("fail to close DB", "err", err)
+	}
+	slog.Info("stopped")
+}
+
+// adminHandler returns the handler of the admin listener, serving the
+// endpoints of adminListenerPath with the authentication of the public
+// ones but without their limits.
+func (s *server) adminHandler(cfg *config, accessLogger *slog.Logger) http.Handler {
+	h := servePathsMiddleware(s.mux, adminListenerPath)
+	if cfg.ReadOnly {
+		h = readOnlyMiddleware(h)
+	}
+	if s.auth != nil {
+		h = authMiddleware(h, s.auth)
+	} else {
+		h = noAuthMiddleware(h)
+	}
+	h = prettyMiddleware(h)
+	h = recoverMiddleware(h)
+	h = accessLogMiddleware(h, accessLogger)
+	return requestIDMiddleware(h)
+}
+
+// newHTTPServer returns the server of h on addr, with the timeouts of
+// cfg protecting it from the slow clients.
+func newHTTPServer(cfg *config, addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           connWriterMiddleware(h),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// connWriterKey is the context key of the ResponseWriter given by the
+// HTTP server, which the compression hides from the handlers.
+type connWriterKey struct{}
+
+// connWriterMiddleware keeps the ResponseWriter of the server in the
+// context of the requests.
+func connWriterMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), connWriterKey{}, w)))
+	})
+}
 
-	log.Println("listening on:", addr)
-	log.Fatal(http.ListenAndServe(addr, h))
+// clearWriteDeadline lifts the write timeout of the response to r, for
+// the downloads which may take longer to read.
+func clearWriteDeadline(w http.ResponseWriter, r *http.Request) {
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if errors.Is(err, http.ErrNotSupported) {
+		if cw, ok := r.Context().Value(connWriterKey{}).(http.ResponseWriter); ok {
+			err = http.NewResponseController(cw).SetWriteDeadline(time.Time{})
+		}
+	}
+	if err != nil {
+		slog.DebugContext(r.Context(), "fail to clear write deadline", "err", err)
+	}
+}
+
+// openStore opens the store selected by the configuration.
+func openStore(cfg *config) (Store, error) {
+	if cfg.DB == memoryDB {
+		return newMemoryStore(), nil
+	}
+	var aead cipher.AEAD
+	if cfg.EncryptionKey != "" {
+		var err error
+		aead, err = newCipher(cfg.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newBoltStore(cfg.DB, aead, cfg.ReadOnly)
+}
+
+// openIndexedStore opens the store selected by the configuration, kept
+// in the search index when one is set.
+func openIndexedStore(cfg *config) (Store, *searchIndex, error) {
+	store, err := openStore(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.SearchIndex == "" {
+		return store, nil, nil
+	}
+	index, err := openSearchIndex(cfg.SearchIndex, store, cfg.ReadOnly)
+	if err != nil {
+		store.Close()
+		return nil, nil, fmt.Errorf("search index: %v", err)
+	}
+	return &indexedStore{Store: store, index: index}, index, nil
 }
 
 func writeError(w http.ResponseWriter, code int, msg string) {
+	// The server errors give the ID of the request to find its logs.
+	if id := w.Header().Get(requestIDHeader); id != "" && code >= 500 {
+		msg += " (request " + id + ")"
+	}
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(code)
 	w.Write([]byte(msg))
 }
 
-func corsMiddleware(h http.Handler) http.Handler {
+// readOnlyMiddleware rejects the requests which could modify data.
+func readOnlyMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Access-Control-Allow-Origin", "*")
-		w.Header().Add("Access-Control-Allow-Methods", "GET, POST, OPTIONS, DELETE")
-		w.Header().Add("Access-Control-Allow-Headers", "Content-Type")
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS":
+			h.ServeHTTP(w, r)
+		default:
+			writeError(w, http.StatusForbidden, "server is read-only")
 		}
-		h.ServeHTTP(w, r)
 	})
 }
 
 func (s *server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Access-Control-Allow-Origin", "*")
 	writeError(w, http.StatusNotFound, "nothing here...")
 }
 
+// prepareArticle checks an article sent by a client and sets the
+// fields owned by the server.
+func prepareArticle(a *article, now time.Time) error {
+	err := validateArticle(a)
+	if err != nil {
+		return err
+	}
+	sanitizeArticle(a)
+	if a.expired(now) {
+		return errors.New("expires_at is in the past")
+	}
+	tags, err := normalizeTags(a.Tags)
+	if err != nil {
+		return err
+	}
+	a.Tags = tags
+	a.Category, err = normalizeCategory(a.Category)
+	if err != nil {
+		return err
+	}
+	a.Metadata, err = normalizeMetadata(a.Metadata)
+	if err != nil {
+		return err
+	}
+	a.Language, err = normalizeLanguage(a.Language)
+	if err != nil {
+		return err
+	}
+	a.Translations, err = normalizeTranslations(a.Translations)
+	if err != nil {
+		return err
+	}
+	if a.Translations[a.Language] != nil {
+		return errors.New("translation in the language of the article")
+	}
+	if a.Status == "" {
+		a.Status = statusPublished
+	}
+	err = checkStatus(a.Status)
+	if err != nil {
+		return err
+	}
+	a.schedule(now)
+	// The UUID and the slug are assigned by the store.
+	a.UUID = ""
+	a.Slug = ""
+	a.Timestamp = now
+	a.Updated = nil
+	a.Deleted = nil
+	return nil
+}
+
 func (s *server) postArticleHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, ok := params["id"]
@@ -112,48 +541,151 @@ func (s *server) postArticleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		writeError(w, http.StatusBadRequest, "invalid content-type")
+	a := &article{}
+	err := decodeArticle(r, a)
+	if err == nil {
+		err = prepareArticle(a, time.Now())
+	}
+	if e, ok := err.(validationError); ok {
+		writeValidationError(w, r, e)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = s.checkCategory(id, a.Category)
+	if e, ok := err.(invalidError); ok {
+		writeError(w, http.StatusBadRequest, e.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
 		return
 	}
 
-	a := &article{}
-	err := json.NewDecoder(r.Body).Decode(a)
+	err = s.storeOf(r).Put(id, a)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "fail to parse JSON")
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
 		return
 	}
-	a.Timestamp = time.Now()
+	writeArticle(w, r, a, nil)
+}
 
-	err = s.db.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([]byte(id))
-		if err != nil {
-			return err
+func (s *server) putArticleHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	in := &article{}
+	err := decodeStrict(r, in)
+	if err == nil {
+		if errs := validateContent(in); errs != nil {
+			err = errs
 		}
-		var buf bytes.Buffer
-		err = gob.NewEncoder(&buf).Encode(a)
+	}
+	if e, ok := err.(validationError); ok {
+		writeValidationError(w, r, e)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if in.Title != "" && in.Title != title {
+		writeError(w, http.StatusBadRequest, "title does not match the URL")
+		return
+	}
+	sanitizeArticle(in)
+	now := time.Now()
+	if in.expired(now) {
+		writeError(w, http.StatusBadRequest, "expires_at is in the past")
+		return
+	}
+	tags, err := normalizeTags(in.Tags)
+	var meta metadata
+	if err == nil {
+		meta, err = normalizeMetadata(in.Metadata)
+	}
+	var lang string
+	if err == nil {
+		lang, err = normalizeLanguage(in.Language)
+	}
+	if err == nil && in.Status != "" {
+		err = checkStatus(in.Status)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	category, err := normalizeCategory(in.Category)
+	if err == nil {
+		err = s.checkCategory(id, category)
+	}
+	if e, ok := err.(invalidError); ok {
+		writeError(w, http.StatusBadRequest, e.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	// The UUID, the creation timestamp and the translations are kept.
+	a, err := s.storeOf(r).Update(id, title, func(a *article) error {
+		err := checkIfMatch(r, a)
 		if err != nil {
 			return err
 		}
-		err = b.Put([]byte(a.Title), buf.Bytes())
-		if err != nil {
-			return err
+		if a.Translations[lang] != nil {
+			return invalidError("translation in the language of the article")
+		}
+		a.Language = lang
+		a.Content = in.Content
+		a.Summary = in.Summary
+		a.Tags = tags
+		a.Category = category
+		a.Metadata = meta
+		a.ExpiresAt = in.ExpiresAt
+		// The status is kept unless given.
+		if in.Status != "" {
+			a.Status = in.Status
 		}
+		a.PublishAt = in.PublishAt
+		a.schedule(now)
+		a.Updated = &now
 		return nil
 	})
-	if err != nil {
-		log.Println("fail to access DB:", err)
-		writeError(w, http.StatusInternalServerError, "fail to access DB")
+	if err == errUnknownID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errPreconditionFailed {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if e, ok := err.(invalidError); ok {
+		writeError(w, http.StatusBadRequest, e.Error())
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(a)
 	if err != nil {
-		log.Println("fail to encode article:", err)
-		writeError(w, http.StatusInternalServerError, "fail to encode response")
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
 		return
 	}
+	w.Header().Set("ETag", articleETag(a))
+	writeArticle(w, r, a, nil)
 }
 
 func (s *server) getArticleHandler(w http.ResponseWriter, r *http.Request) {
@@ -169,29 +701,39 @@ func (s *server) getArticleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a := &article{}
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(id))
-		if b == nil {
-			return errUnknownID
-		}
-		data := b.Get([]byte(title))
-		if data == nil {
-			return errUnknownTitle
-		}
-		return gob.NewDecoder(bytes.NewReader(data)).Decode(a)
-	})
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a, err := s.storeOf(r).Get(id, title)
+	if err == nil && (a.expired(time.Now()) || s.hidden(r, id, a)) {
+		err = errUnknownTitle
+	}
 	if err == errUnknownID || err == errUnknownTitle {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
 		writeError(w, http.StatusInternalServerError, "fail to access DB")
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(a)
+	variant, err := localize(w, r, a)
+	if err == errUnknownLanguage {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.views.add(r, id, a.UUID)
+	if notModified(w, r, articleETag(a)) {
+		return
+	}
+	writeArticle(w, r, variant, fields)
 }
 
 func (s *server) deleteArticleHandler(w http.ResponseWriter, r *http.Request) {
@@ -207,94 +749,218 @@ func (s *server) deleteArticleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(id))
-		if b == nil {
-			return errUnknownID
-		}
-		data := b.Get([]byte(title))
-		if data == nil {
-			return errUnknownTitle
-		}
-		return b.Delete([]byte(title))
+	err := s.storeOf(r).Delete(id, title, func(a *article) error {
+		return checkIfMatch(r, a)
 	})
 	if err == errUnknownID || err == errUnknownTitle {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if err == errPreconditionFailed {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
 		writeError(w, http.StatusInternalServerError, "fail to access DB")
 		return
 	}
 }
 
-func (s *server) getArticlesHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) getArticleByUUIDHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, ok := params["id"]
 	if !ok || id == "" {
 		writeError(w, http.StatusBadRequest, "missing ID")
 		return
 	}
+	uuid, ok := params["uuid"]
+	if !ok || uuid == "" {
+		writeError(w, http.StatusBadRequest, "missing UUID")
+		return
+	}
 
-	var articles []*article
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(id))
-		if b == nil {
-			return errUnknownID
-		}
-		return b.ForEach(func(k, v []byte) error {
-			a := &article{}
-			err := gob.NewDecoder(bytes.NewReader(v)).Decode(a)
-			if err != nil {
-				return err
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a, err := s.storeOf(r).GetByUUID(id, uuid)
+	if err == nil && (a.expired(time.Now()) || s.hidden(r, id, a)) {
+		err = errUnknownUUID
+	}
+	if err == errUnknownID || err == errUnknownUUID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	variant, err := localize(w, r, a)
+	if err == errUnknownLanguage {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.views.add(r, id, a.UUID)
+	if notModified(w, r, articleETag(a)) {
+		return
+	}
+	writeArticle(w, r, variant, fields)
+}
+
+func (s *server) deleteArticleByUUIDHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	uuid, ok := params["uuid"]
+	if !ok || uuid == "" {
+		writeError(w, http.StatusBadRequest, "missing UUID")
+		return
+	}
+
+	a, err := s.storeOf(r).GetByUUID(id, uuid)
+	if err == nil {
+		err = s.storeOf(r).Delete(id, a.Title, func(b *article) error {
+			if b.UUID != uuid {
+				return errUnknownUUID
 			}
-			articles = append(articles, a)
-			return nil
+			return checkIfMatch(r, b)
 		})
-	})
+	}
+	if err == errUnknownID || err == errUnknownUUID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errPreconditionFailed {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+}
+
+func (s *server) getArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	q, err := parseListQuery(r, params["sort"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.checkListStatus(w, r, id, q) {
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	summary, err := parseView(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if negotiate(r, listTypes) == ndjsonType {
+		s.streamArticles(w, r, id, q, fields, summary)
+		return
+	}
+
+	articles, total, next, err := q.list(s.storeOf(r), id)
 	if err == errUnknownID {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
 		writeError(w, http.StatusInternalServerError, "fail to access DB")
 		return
 	}
 
-	order, ok := params["sort"]
-	if ok {
-		if order == "asc" {
-			sort.Slice(articles, func(i, j int) bool { return articles[i].Timestamp.Before(articles[j].Timestamp) })
-		} else if order == "desc" {
-			sort.Slice(articles, func(i, j int) bool { return articles[i].Timestamp.After(articles[j].Timestamp) })
-		} else {
-			writeError(w, http.StatusBadRequest, "invalid sort parameter")
-			return
-		}
+	if summary {
+		articles = summarize(articles)
 	}
+	writeArticles(w, r, q, articles, total, next, fields)
+}
 
-	accept := r.Header.Get("Accept")
-	asXML := strings.Contains(accept, "text/xml")
-	asJSON := strings.Contains(accept, "application/json")
-	if asXML && !asJSON {
-		w.Header().Set("Content-Type", "text/xml")
-		err = xml.NewEncoder(w).Encode(struct {
+// writeArticles writes a page of articles listed by q, in the media
+// type negotiated for r.
+func writeArticles(w http.ResponseWriter, r *http.Request, q *listQuery, articles []*article, total int, next string, fields []string) {
+	if total >= 0 {
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	}
+	if next != "" {
+		u := *r.URL
+		values := u.Query()
+		values.Set("cursor", next)
+		values.Del("offset")
+		u.RawQuery = values.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, linkPath(r, u.RequestURI())))
+	}
+	mediaType := responseType(w, r, articleTypes)
+	if mediaType == "" {
+		return
+	}
+	// The listing is encoded before being sent to compute its ETag.
+	var err error
+	buf := &bytes.Buffer{}
+	switch mediaType {
+	case protobufType:
+		for i, a := range articles {
+			articles[i] = selectFields(a, fields)
+		}
+		buf.Write(marshalArticleList(articles))
+	case jsonAPIType:
+		var data []byte
+		data, err = marshalJSON(jsonAPIArticles(r, q, articles, total, next, fields))
+		buf.Write(data)
+	case halType:
+		var data []byte
+		data, err = marshalJSON(halArticles(r, q, articles, total, next, fields))
+		buf.Write(data)
+	case xmlType:
+		for i, a := range articles {
+			articles[i] = selectFields(a, fields)
+		}
+		err = xml.NewEncoder(buf).Encode(struct {
 			XMLName  xml.Name
 			Articles []*article `xml:"article"`
 		}{
 			XMLName:  xml.Name{Local: "articles"},
 			Articles: articles,
 		})
-	} else {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(articles)
+	default:
+		var data []byte
+		data, err = codecOf(mediaType).marshal(jsonArticles(articles, fields))
+		buf.Write(data)
 	}
 	if err != nil {
-		log.Println("encoding fail:", err)
+		slog.ErrorContext(r.Context(), "encoding fail", "err", err)
 		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	if notModified(w, r, etagOf(buf.Bytes())) {
+		return
 	}
+	w.Header().Set("Content-Type", mediaType)
+	buf.WriteTo(w)
 }
 
 func (s *server) deleteArticlesHandler(w http.ResponseWriter, r *http.Request) {
@@ -305,19 +971,35 @@ func (s *server) deleteArticlesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(id))
-		if b == nil {
-			return errUnknownID
+	f, err := parseDeleteFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f != nil {
+		n, err := s.storeOf(r).DeleteFunc(id, f.match)
+		if err == errUnknownID {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
 		}
-		return tx.DeleteBucket([]byte(id))
-	})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+			writeError(w, http.StatusInternalServerError, "fail to access DB")
+			return
+		}
+		writeResponse(w, r, struct {
+			Deleted int `json:"deleted"`
+		}{n})
+		return
+	}
+
+	err = s.storeOf(r).DeleteAll(id)
 	if err == errUnknownID {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
 		writeError(w, http.StatusInternalServerError, "fail to access DB")
 		return
 	}
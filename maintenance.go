@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultMaintenanceRetry is the number of seconds the clients are told
+// to wait during maintenance when the admin does not tell.
+const defaultMaintenanceRetry = 60
+
+// maintenanceBody is the body of the maintenance endpoints.
+type maintenanceBody struct {
+	Enabled bool `json:"enabled"`
+	// RetryAfter is the number of seconds the clients are told to wait
+	// before retrying.
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Since is when the maintenance started.
+	Since *time.Time `json:"since,omitempty"`
+}
+
+// maintenance is the maintenance mode of the server, when enabled the
+// endpoints not needed by the admins answer 503 Service Unavailable.
+type maintenance struct {
+	mu    sync.RWMutex
+	state maintenanceBody
+}
+
+// get returns the state of the maintenance.
+func (m *maintenance) get() maintenanceBody {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// set enables or disables the maintenance and logs the change.
+func (m *maintenance) set(enabled bool, retryAfter int) maintenanceBody {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !enabled {
+		if m.state.Enabled {
+			slog.Warn("maintenance disabled", "since", *m.state.Since)
+		}
+		m.state = maintenanceBody{}
+		return m.state
+	}
+	if retryAfter <= 0 {
+		retryAfter = defaultMaintenanceRetry
+	}
+	if !m.state.Enabled {
+		now := time.Now().UTC()
+		m.state.Since = &now
+		slog.Warn("maintenance enabled", "retry_after", retryAfter)
+	}
+	m.state.Enabled = true
+	m.state.RetryAfter = retryAfter
+	return m.state
+}
+
+// maintenancePath reports whether path is served during maintenance: the
+// admin endpoints, the sessions of the admins, the liveness probe and
+// the metrics.
+func maintenancePath(path string) bool {
+	switch path {
+	case "/login", "/logout", "/healthz", "/metrics":
+		return true
+	}
+	return adminPath(path)
+}
+
+// maintenanceMiddleware answers 503 Service Unavailable, with the delay
+// to retry after, to the requests not served during maintenance.
+func maintenanceMiddleware(h http.Handler, m *maintenance) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := m.get()
+		if state.Enabled && !maintenancePath(r.URL.Path) {
+			w.Header().Set("Retry-After", strconv.Itoa(state.RetryAfter))
+			writeError(w, http.StatusServiceUnavailable, "server under maintenance")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// getMaintenanceHandler sends the state of the maintenance.
+func (s *server) getMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	state := s.maintenance.get()
+	writeResponse(w, r, &state)
+}
+
+// postMaintenanceHandler enables or disables the maintenance, until the
+// server restarts.
+func (s *server) postMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	var body maintenanceBody
+	err := decodeRequest(r, &body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.RetryAfter < 0 {
+		writeError(w, http.StatusBadRequest, "invalid retry_after")
+		return
+	}
+	state := s.maintenance.set(body.Enabled, body.RetryAfter)
+	writeResponse(w, r, &state)
+}
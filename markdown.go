@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday"
+)
+
+// htmlType is the media type of the content of an article rendered as
+// HTML.
+const htmlType = "text/html"
+
+// htmlPolicy sanitizes the rendered content, it keeps the elements
+// found in user generated content and removes the scripts, the styles
+// and the event handlers.
+var htmlPolicy = bluemonday.UGCPolicy()
+
+// renderMarkdown returns the sanitized HTML of the Markdown content.
+func renderMarkdown(content string) []byte {
+	return htmlPolicy.SanitizeBytes(blackfriday.Run([]byte(content)))
+}
+
+// writeHTML writes the content of a rendered as HTML.
+func writeHTML(w http.ResponseWriter, a *article) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(renderMarkdown(a.Content))
+}
+
+func (s *server) getArticleHTMLHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	a, err := s.storeOf(r).Get(id, title)
+	if err == nil && (a.expired(time.Now()) || s.hidden(r, id, a)) {
+		err = errUnknownTitle
+	}
+	if err == errUnknownID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	variant, err := localize(w, r, a)
+	if err == errUnknownLanguage {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.views.add(r, id, a.UUID)
+	if notModified(w, r, articleETag(a)) {
+		return
+	}
+	writeHTML(w, variant)
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryDB is the BLOG_API_DB value selecting the in-memory store.
+const memoryDB = ":memory:"
+
+// memoryStore is a Store keeping articles in memory. Nothing is
+// persisted, which makes it suitable for tests and demos.
+type memoryStore struct {
+	mu    sync.RWMutex
+	users map[string]map[string]article
+	trash map[string]map[string]article
+	// categories holds the categories of each user by path.
+	categories map[string]map[string]category
+	// keys holds the API keys by hash of their secret.
+	keys map[string]apiKey
+	// reactions holds the reactions of the clients, keyed by user ID
+	// and reactionKey separated by a zero byte.
+	reactions map[string]bool
+	// views counts the views of the articles by day.
+	views map[viewKey]int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:      make(map[string]map[string]article),
+		trash:      make(map[string]map[string]article),
+		categories: make(map[string]map[string]category),
+	}
+}
+
+func (s *memoryStore) Get(id, title string) (*article, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return nil, errUnknownID
+	}
+	a, ok := articles[title]
+	if !ok {
+		return nil, errUnknownTitle
+	}
+	return &a, nil
+}
+
+func (s *memoryStore) GetByUUID(id, uuid string) (*article, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return nil, errUnknownID
+	}
+	for _, a := range articles {
+		if a.UUID == uuid {
+			return &a, nil
+		}
+	}
+	return nil, errUnknownUUID
+}
+
+func (s *memoryStore) GetBySlug(id, slug string) (*article, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return nil, errUnknownID
+	}
+	for _, a := range articles {
+		if a.Slug == slug {
+			return &a, nil
+		}
+	}
+	return nil, errUnknownSlug
+}
+
+func (s *memoryStore) Put(id string, a *article) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a.Reactions, a.Views = nil, 0
+	s.put(id, a)
+	return nil
+}
+
+func (s *memoryStore) PutAll(id string, articles []*article) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range articles {
+		a.Reactions, a.Views = nil, 0
+		s.put(id, a)
+	}
+	return nil
+}
+
+func (s *memoryStore) Update(id, title string, fn func(a *article) error) (*article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return nil, errUnknownID
+	}
+	a, ok := articles[title]
+	if !ok {
+		return nil, errUnknownTitle
+	}
+	err := fn(&a)
+	if err != nil {
+		return nil, err
+	}
+	a.UUID = articles[title].UUID
+	a.Slug = articles[title].Slug
+	a.Reactions = articles[title].Reactions
+	a.Views = 0
+	if _, ok := articles[a.Title]; ok && a.Title != title {
+		return nil, errTitleExists
+	}
+	delete(articles, title)
+	articles[a.Title] = a
+	return &a, nil
+}
+
+// put stores a for user id, s.mu must be held for writing. The article
+// keeps the reactions of the one it replaces.
+func (s *memoryStore) put(id string, a *article) {
+	articles, ok := s.users[id]
+	if !ok {
+		articles = make(map[string]article)
+		s.users[id] = articles
+	}
+	if old, ok := articles[a.Title]; ok {
+		a.UUID = old.UUID
+	} else if a.UUID == "" {
+		a.UUID = newUUID()
+	}
+	for title, old := range articles {
+		if old.UUID == a.UUID {
+			a.Reactions = old.Reactions
+			if a.Slug == "" {
+				a.Slug = old.Slug
+			}
+			delete(articles, title)
+		}
+	}
+	taken := func(slug string) bool {
+		for _, old := range articles {
+			if old.Slug == slug {
+				return true
+			}
+		}
+		return false
+	}
+	if a.Slug == "" || taken(a.Slug) {
+		a.Slug = uniqueSlug(a.Title, taken)
+	}
+	articles[a.Title] = *a
+}
+
+func (s *memoryStore) Delete(id, title string, check func(a *article) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return errUnknownID
+	}
+	a, ok := articles[title]
+	if !ok {
+		return errUnknownTitle
+	}
+	if check != nil {
+		err := check(&a)
+		if err != nil {
+			return err
+		}
+	}
+	s.moveToTrash(id, a, time.Now())
+	return nil
+}
+
+// moveToTrash moves the article a of user id to the trash, s.mu must
+// be held for writing.
+func (s *memoryStore) moveToTrash(id string, a article, now time.Time) {
+	delete(s.users[id], a.Title)
+	a.Deleted = &now
+	trash, ok := s.trash[id]
+	if !ok {
+		trash = make(map[string]article)
+		s.trash[id] = trash
+	}
+	trash[a.UUID] = a
+}
+
+func (s *memoryStore) List(id string) ([]*article, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return nil, errUnknownID
+	}
+	return sortedArticles(articles), nil
+}
+
+func (s *memoryStore) Walk(id string, o order, after []byte, fn func(a *article) bool) error {
+	s.mu.RLock()
+	articles, ok := s.users[id]
+	if !ok {
+		s.mu.RUnlock()
+		return errUnknownID
+	}
+	list := sortedArticles(articles)
+	s.mu.RUnlock()
+
+	if o != byTitle {
+		sort.Slice(list, func(i, j int) bool {
+			return bytes.Compare(walkKey(o, list[i]), walkKey(o, list[j])) < 0
+		})
+	}
+	if o == byTimeDesc {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+	for _, a := range list {
+		if after != nil {
+			c := bytes.Compare(walkKey(o, a), after)
+			if c == 0 || (c < 0) != (o == byTimeDesc) {
+				continue
+			}
+		}
+		if !fn(a) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// sortedArticles returns copies of the articles ordered by title, like
+// the Bolt store does.
+func sortedArticles(articles map[string]article) []*article {
+	list := make([]*article, 0, len(articles))
+	for _, a := range articles {
+		a := a
+		list = append(list, &a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Title < list[j].Title })
+	return list
+}
+
+func (s *memoryStore) Users() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]string, 0, len(s.users))
+	for id := range s.users {
+		users = append(users, id)
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+func (s *memoryStore) DeleteAll(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return errUnknownID
+	}
+	now := time.Now()
+	for _, a := range articles {
+		s.moveToTrash(id, a, now)
+	}
+	delete(s.users, id)
+	return nil
+}
+
+func (s *memoryStore) DeleteFunc(id string, match func(a *article) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return 0, errUnknownID
+	}
+	n := 0
+	now := time.Now()
+	for _, a := range articles {
+		if match(&a) {
+			s.moveToTrash(id, a, now)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) Tags(id string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return nil, errUnknownID
+	}
+	tags := make(map[string]int)
+	for _, a := range articles {
+		for _, t := range a.Tags {
+			tags[t]++
+		}
+	}
+	return tags, nil
+}
+
+func (s *memoryStore) Stats(id string) (*articleStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	articles, ok := s.users[id]
+	if !ok {
+		return nil, errUnknownID
+	}
+	stats := &articleStats{}
+	for _, a := range articles {
+		data, err := encodeRecord(&a)
+		if err != nil {
+			return nil, err
+		}
+		stats.Count++
+		stats.Bytes += int64(len(data))
+		t := a.Timestamp.UTC()
+		if stats.Oldest == nil || t.Before(*stats.Oldest) {
+			stats.Oldest = &t
+		}
+		if stats.Newest == nil || t.After(*stats.Newest) {
+			stats.Newest = &t
+		}
+	}
+	return stats, nil
+}
+
+func (s *memoryStore) Trash(id string) ([]*article, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	trash, ok := s.trash[id]
+	if !ok {
+		return nil, errUnknownID
+	}
+	articles := sortedArticles(trash)
+	sortTrash(articles)
+	return articles, nil
+}
+
+func (s *memoryStore) Restore(id, uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trash, ok := s.trash[id]
+	if !ok {
+		return errUnknownID
+	}
+	a, ok := trash[uuid]
+	if !ok {
+		return errUnknownUUID
+	}
+	articles, ok := s.users[id]
+	if !ok {
+		articles = make(map[string]article)
+		s.users[id] = articles
+	}
+	if _, ok := articles[a.Title]; ok {
+		return errTitleExists
+	}
+	a.Deleted = nil
+	s.put(id, &a)
+	delete(trash, uuid)
+	return nil
+}
+
+func (s *memoryStore) Purge(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, trash := range s.trash {
+		for uuid, a := range trash {
+			if a.Deleted != nil && a.Deleted.Before(before) {
+				delete(trash, uuid)
+				s.deleteReactions(id, a.UUID)
+				for k := range s.views {
+					if k.id == id && k.uuid == a.UUID {
+						delete(s.views, k)
+					}
+				}
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) Category(id, path string) (*category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.categories[id][path]
+	if !ok {
+		return nil, errUnknownCategory
+	}
+	return &c, nil
+}
+
+func (s *memoryStore) Categories(id string) ([]*category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var categories []*category
+	for _, c := range s.categories[id] {
+		c := c
+		categories = append(categories, &c)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Path < categories[j].Path })
+	return categories, nil
+}
+
+func (s *memoryStore) PutCategory(id string, c *category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	categories, ok := s.categories[id]
+	if !ok {
+		categories = make(map[string]category)
+		s.categories[id] = categories
+	}
+	if parent := c.parent(); parent != "" {
+		if _, ok := categories[parent]; !ok {
+			return errUnknownParent
+		}
+	}
+	categories[c.Path] = *c
+	return nil
+}
+
+func (s *memoryStore) DeleteCategory(id, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	categories := s.categories[id]
+	if _, ok := categories[path]; !ok {
+		return errUnknownCategory
+	}
+	for p := range categories {
+		if strings.HasPrefix(p, path+"/") {
+			return errCategoryInUse
+		}
+	}
+	for _, a := range s.users[id] {
+		if a.Category == path {
+			return errCategoryInUse
+		}
+	}
+	delete(categories, path)
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+// metaPrefix prefixes the query parameters filtering the articles on
+// their metadata, e.g. ?meta.author=alice.
+const metaPrefix = "meta."
+
+// metadata holds custom fields of an article.
+type metadata map[string]string
+
+// normalizeMetadata trims the spaces around the keys and rejects the
+// empty ones. It returns nil for an empty metadata.
+func normalizeMetadata(m metadata) (metadata, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	normalized := make(metadata, len(m))
+	for k, v := range m {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			return nil, invalidError("invalid metadata key")
+		}
+		normalized[k] = v
+	}
+	return normalized, nil
+}
+
+// MarshalXML encodes the metadata as a list of entries sorted by key,
+// since maps are not supported by encoding/xml.
+func (m metadata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		entry := xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: k}},
+		}
+		err = e.EncodeElement(m[k], entry)
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML decodes the entries encoded by MarshalXML.
+func (m *metadata) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var entries struct {
+		Entries []struct {
+			Key   string `xml:"key,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"entry"`
+	}
+	err := d.DecodeElement(&entries, &start)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries.Entries {
+		if *m == nil {
+			*m = make(metadata)
+		}
+		(*m)[e.Key] = e.Value
+	}
+	return nil
+}
+
+// hasMeta reports whether a has all the metadata of filter.
+func (a *article) hasMeta(filter map[string]string) bool {
+	for k, v := range filter {
+		if got, ok := a.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
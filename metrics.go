@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newMetricsHandler returns the handler sending the Prometheus metrics
+// of the server and of the database of store.
+func newMetricsHandler(store Store) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewExpvarCollector(map[string]*prometheus.Desc{
+			"recovered_panics": prometheus.NewDesc("blog_recovered_panics_total",
+				"Panics of the handlers served as 500.", nil, nil),
+		}),
+	)
+	if st, ok := baseStore(store).(dbStatser); ok {
+		reg.MustRegister(&dbStatsCollector{st})
+	}
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
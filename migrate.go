@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// metaBucket records the migrations applied to a Bolt database.
+var metaBucket = []byte("_meta")
+
+// migration changes the layout or the records of a Bolt database.
+// Migrations are applied in order, each one in its own transaction.
+type migration struct {
+	version uint64
+	name    string
+	apply   func(s *boltStore, tx *bolt.Tx) error
+}
+
+// migrations lists all the migrations, new migrations must be appended
+// with an increasing version.
+var migrations = []migration{
+	{1, "convert gob articles to JSON records", migrateGobRecords},
+	{2, "move user buckets under the articles bucket", migrateArticlesBucket},
+	{3, "create the trash bucket", createBucket(trashBucket)},
+	{4, "index articles by timestamp", migrateTimeIndex},
+	{5, "key articles by UUID", migrateUUIDKeys},
+	{6, "create the quarantine bucket", createBucket(quarantineBucket)},
+	{7, "index articles by slug", migrateSlugIndex},
+	{8, "create the tag index", migrateTagIndex},
+	{9, "create the categories bucket", createBucket(categoriesBucket)},
+	{10, "publish the existing articles", migratePublished},
+	{11, "create the reactions bucket", createBucket(reactionsBucket)},
+	{12, "create the views bucket", createBucket(viewsBucket)},
+	{13, "key the trash by UUID", migrateTrashUUIDKeys},
+	{14, "index articles by due time", migrateDueIndex},
+}
+
+// appliedMigration is the value stored in the meta bucket for each
+// applied migration.
+type appliedMigration struct {
+	Name    string    `json:"name"`
+	Applied time.Time `json:"applied"`
+}
+
+func migrationKey(version uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, version)
+	return k
+}
+
+// schemaVersion returns the version of the last migration applied.
+func schemaVersion(tx *bolt.Tx) uint64 {
+	b := tx.Bucket(metaBucket)
+	if b == nil {
+		return 0
+	}
+	k, _ := b.Cursor().Last()
+	if k == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(k)
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database.
+func (s *boltStore) SchemaVersion() (uint64, error) {
+	var v uint64
+	err := s.view(func(tx *bolt.Tx) error {
+		v = schemaVersion(tx)
+		return nil
+	})
+	return v, err
+}
+
+// checkSchema returns an error if migrations are missing from the
+// database, it is used when the database cannot be written.
+func (s *boltStore) checkSchema() error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		v := schemaVersion(tx)
+		last := migrations[len(migrations)-1].version
+		if v < last {
+			return fmt.Errorf("database schema version is %d, expected %d", v, last)
+		}
+		return nil
+	})
+}
+
+// migrate applies the migrations missing from the database.
+func (s *boltStore) migrate() error {
+	for _, m := range migrations {
+		m := m
+		applied := false
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			if schemaVersion(tx) >= m.version {
+				return nil
+			}
+			err := m.apply(s, tx)
+			if err != nil {
+				return err
+			}
+			b, err := tx.CreateBucketIfNotExists(metaBucket)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(&appliedMigration{Name: m.name, Applied: time.Now()})
+			if err != nil {
+				return err
+			}
+			applied = true
+			return b.Put(migrationKey(m.version), data)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d: %v", m.version, err)
+		}
+		if applied {
+			slog.Info("applied migration", "version", m.version, "name", m.name)
+		}
+	}
+	return nil
+}
+
+// createBucket returns a migration creating a top level bucket.
+func createBucket(name []byte) func(s *boltStore, tx *bolt.Tx) error {
+	return func(s *boltStore, tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(name)
+		return err
+	}
+}
+
+// legacyBuckets returns the names of the user buckets created before
+// the articles bucket existed.
+func legacyBuckets(tx *bolt.Tx) [][]byte {
+	var names [][]byte
+	tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if bytes.Equal(name, metaBucket) {
+			return nil
+		}
+		names = append(names, append([]byte(nil), name...))
+		return nil
+	})
+	return names
+}
+
+// migrateGobRecords converts the gob encoded articles written by older
+// versions of the server to JSON records.
+func migrateGobRecords(s *boltStore, tx *bolt.Tx) error {
+	for _, name := range legacyBuckets(tx) {
+		b := tx.Bucket(name)
+		// Values cannot be updated while iterating a bucket, collect
+		// the converted records first.
+		updates := make(map[string][]byte)
+		err := b.ForEach(func(k, v []byte) error {
+			if _, err := decodeRecord(v); err == nil {
+				return nil
+			}
+			a := &article{}
+			err := gob.NewDecoder(bytes.NewReader(v)).Decode(a)
+			if err != nil {
+				return err
+			}
+			data, err := encodeRecord(a)
+			if err != nil {
+				return err
+			}
+			updates[string(k)] = data
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, v := range updates {
+			err = b.Put([]byte(k), v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrateArticlesBucket moves the top level user buckets inside the
+// articles bucket, freeing the top level for the server own buckets.
+func migrateArticlesBucket(s *boltStore, tx *bolt.Tx) error {
+	names := legacyBuckets(tx)
+	root, err := tx.CreateBucket(articlesBucket)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		dst, err := root.CreateBucket(name)
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(name).ForEach(func(k, v []byte) error {
+			return dst.Put(k, v)
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.DeleteBucket(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTimeIndex creates the timestamp index of the existing
+// articles.
+func migrateTimeIndex(s *boltStore, tx *bolt.Tx) error {
+	root, err := tx.CreateBucket(timeIndexBucket)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(articlesBucket).ForEach(func(id, _ []byte) error {
+		idx, err := root.CreateBucket(id)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(articlesBucket).Bucket(id).ForEach(func(k, v []byte) error {
+			a, err := s.unmarshal(v)
+			if err != nil {
+				return err
+			}
+			return idx.Put(timeKey(a.Timestamp, a.Title), nil)
+		})
+	})
+}
+
+// migrateUUIDKeys assigns a UUID to the existing articles, keys them by
+// UUID and indexes their title.
+func migrateUUIDKeys(s *boltStore, tx *bolt.Tx) error {
+	titles, err := tx.CreateBucket(titleIndexBucket)
+	if err != nil {
+		return err
+	}
+	times := tx.Bucket(timeIndexBucket)
+	err = tx.Bucket(articlesBucket).ForEach(func(id, _ []byte) error {
+		b := tx.Bucket(articlesBucket).Bucket(id)
+		var keys [][]byte
+		var articles []*article
+		err := b.ForEach(func(k, v []byte) error {
+			a, err := s.unmarshal(v)
+			if err != nil {
+				return err
+			}
+			a.UUID = newUUID()
+			keys = append(keys, append([]byte(nil), k...))
+			articles = append(articles, a)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			err = b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		err = times.DeleteBucket(id)
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		tb, err := times.CreateBucket(id)
+		if err != nil {
+			return err
+		}
+		ti, err := titles.CreateBucket(id)
+		if err != nil {
+			return err
+		}
+		for _, a := range articles {
+			data, err := s.marshal(a)
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(a.UUID), data)
+			if err != nil {
+				return err
+			}
+			err = ti.Put([]byte(a.Title), []byte(a.UUID))
+			if err != nil {
+				return err
+			}
+			err = tb.Put(timeKey(a.Timestamp, a.UUID), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Trashed articles keep their title as key but need a UUID to be
+	// restored.
+	return tx.Bucket(trashBucket).ForEach(func(id, _ []byte) error {
+		b := tx.Bucket(trashBucket).Bucket(id)
+		updates := make(map[string][]byte)
+		err := b.ForEach(func(k, v []byte) error {
+			a, err := s.unmarshal(v)
+			if err != nil {
+				return err
+			}
+			a.UUID = newUUID()
+			data, err := s.marshal(a)
+			if err != nil {
+				return err
+			}
+			updates[string(k)] = data
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, v := range updates {
+			err = b.Put([]byte(k), v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrateSlugIndex assigns a slug to the existing articles and indexes
+// them. The oldest articles get the slugs without suffix.
+func migrateSlugIndex(s *boltStore, tx *bolt.Tx) error {
+	root, err := tx.CreateBucket(slugIndexBucket)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(articlesBucket).ForEach(func(id, _ []byte) error {
+		idx, err := root.CreateBucket(id)
+		if err != nil {
+			return err
+		}
+		// The buckets are those of the schema version 6, buckets
+		// expects the current ones.
+		b := tx.Bucket(articlesBucket).Bucket(id)
+		var articles []*article
+		err = tx.Bucket(timeIndexBucket).Bucket(id).ForEach(func(k, _ []byte) error {
+			a, err := s.unmarshal(b.Get(k[timeKeyLen:]))
+			if err != nil {
+				return err
+			}
+			articles = append(articles, a)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, a := range articles {
+			a.Slug = uniqueSlug(a.Title, func(slug string) bool {
+				return idx.Get([]byte(slug)) != nil
+			})
+			data, err := s.marshal(a)
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(a.UUID), data)
+			if err != nil {
+				return err
+			}
+			err = idx.Put([]byte(a.Slug), []byte(a.UUID))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrateTagIndex creates the tag index buckets of the existing users,
+// no article has tags yet.
+func migrateTagIndex(s *boltStore, tx *bolt.Tx) error {
+	root, err := tx.CreateBucket(tagIndexBucket)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(articlesBucket).ForEach(func(id, _ []byte) error {
+		_, err := root.CreateBucket(id)
+		return err
+	})
+}
+
+// migratePublished sets the status of the existing articles, including
+// the deleted ones, to published.
+func migratePublished(s *boltStore, tx *bolt.Tx) error {
+	for _, name := range [][]byte{articlesBucket, trashBucket} {
+		root := tx.Bucket(name)
+		err := root.ForEach(func(id, _ []byte) error {
+			b := root.Bucket(id)
+			updates := make(map[string][]byte)
+			err := b.ForEach(func(k, v []byte) error {
+				a, err := s.unmarshal(v)
+				if err != nil {
+					return err
+				}
+				if a.Status != "" {
+					return nil
+				}
+				a.Status = statusPublished
+				data, err := s.marshal(a)
+				if err != nil {
+					return err
+				}
+				updates[string(k)] = data
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for k, v := range updates {
+				err = b.Put([]byte(k), v)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTrashUUIDKeys keys the deleted articles by UUID instead of
+// title, a title can then be deleted more than once.
+func migrateTrashUUIDKeys(s *boltStore, tx *bolt.Tx) error {
+	root := tx.Bucket(trashBucket)
+	return root.ForEach(func(id, _ []byte) error {
+		b := root.Bucket(id)
+		updates := make(map[string][]byte)
+		var titles [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			a, err := s.unmarshal(v)
+			if err != nil {
+				return err
+			}
+			updates[a.UUID] = append([]byte(nil), v...)
+			titles = append(titles, append([]byte(nil), k...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range titles {
+			err = b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		for k, v := range updates {
+			err = b.Put([]byte(k), v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrateDueIndex indexes the existing articles by the times they expire
+// or are published at.
+func migrateDueIndex(s *boltStore, tx *bolt.Tx) error {
+	root, err := tx.CreateBucket(dueIndexBucket)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(articlesBucket).ForEach(func(id, _ []byte) error {
+		idx, err := root.CreateBucket(id)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(articlesBucket).Bucket(id).ForEach(func(k, v []byte) error {
+			a, err := s.unmarshal(v)
+			if err != nil {
+				return err
+			}
+			for _, t := range dueTimes(a) {
+				err = idx.Put(timeKey(t, a.UUID), nil)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// baselineArticle is an article as stored by the first version of the
+// server, gob encoded in a top level bucket per user, keyed by title.
+type baselineArticle struct {
+	Title     string
+	Content   string
+	Timestamp time.Time
+}
+
+// writeBaselineDB creates at path a database of the first version of
+// the server holding articles for user id.
+func writeBaselineDB(t *testing.T, path, id string, articles ...baselineArticle) {
+	t.Helper()
+	db, err := bolt.Open(path, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return err
+		}
+		for _, a := range articles {
+			var buf bytes.Buffer
+			err = gob.NewEncoder(&buf).Encode(&a)
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(a.Title), buf.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateBaselineDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blog.db")
+	now := time.Now().UTC().Truncate(time.Second)
+	writeBaselineDB(t, path, "alice",
+		baselineArticle{"Hello World", "first", now.Add(-time.Hour)},
+		baselineArticle{"Hello, World", "second", now},
+	)
+
+	s, err := newBoltStore(path, nil, false)
+	if err != nil {
+		t.Fatalf("fail to migrate: %v", err)
+	}
+	defer s.Close()
+
+	v, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := migrations[len(migrations)-1].version; v != last {
+		t.Fatalf("schema version is %d, expected %d", v, last)
+	}
+
+	a, err := s.Get("alice", "Hello World")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Content != "first" || a.UUID == "" || a.Status != statusPublished {
+		t.Errorf("unexpected article %+v", a)
+	}
+	// The oldest article gets the slug without suffix.
+	if a.Slug != "hello-world" {
+		t.Errorf("slug is %q, expected %q", a.Slug, "hello-world")
+	}
+	b, err := s.GetBySlug("alice", "hello-world-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Title != "Hello, World" {
+		t.Errorf("slug hello-world-2 is %q, expected %q", b.Title, "Hello, World")
+	}
+
+	var titles []string
+	err = s.Walk("alice", byTimeDesc, nil, func(a *article) bool {
+		titles = append(titles, a.Title)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(titles) != 2 || titles[0] != "Hello, World" {
+		t.Errorf("unexpected listing %q", titles)
+	}
+
+	// The migrated articles are writable through the current buckets.
+	_, err = s.Update("alice", "Hello World", func(a *article) error {
+		a.Content = "updated"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
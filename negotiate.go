@@ -0,0 +1,98 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a media range of an Accept header, type and subtype
+// may be "*".
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// specificity ranks the media ranges, a range matching a media type
+// with a higher specificity overrides the others.
+func (m *mediaRange) specificity() int {
+	switch {
+	case m.typ == "*":
+		return 0
+	case m.subtype == "*":
+		return 1
+	}
+	return 2
+}
+
+// match reports whether the media type typ/subtype is in m.
+func (m *mediaRange) match(typ, subtype string) bool {
+	return (m.typ == "*" || m.typ == typ) && (m.subtype == "*" || m.subtype == subtype)
+}
+
+// parseAccept returns the media ranges of an Accept header, the invalid
+// ones are left out.
+func parseAccept(header string) []*mediaRange {
+	var ranges []*mediaRange
+	for _, s := range strings.Split(header, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+		slash := strings.IndexByte(mediaType, '/')
+		if slash < 0 {
+			continue
+		}
+		m := &mediaRange{typ: mediaType[:slash], subtype: mediaType[slash+1:], q: 1}
+		if m.typ == "*" && m.subtype != "*" {
+			continue
+		}
+		if v, ok := params["q"]; ok {
+			m.q, err = strconv.ParseFloat(v, 64)
+			if err != nil || m.q < 0 || m.q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, m)
+	}
+	return ranges
+}
+
+// quality returns the quality of mediaType for the ranges, from the
+// most specific range matching it, or 0 when no range matches it.
+func quality(ranges []*mediaRange, mediaType string) float64 {
+	slash := strings.IndexByte(mediaType, '/')
+	typ, subtype := mediaType[:slash], mediaType[slash+1:]
+	var best *mediaRange
+	for _, m := range ranges {
+		if m.match(typ, subtype) && (best == nil || m.specificity() > best.specificity()) {
+			best = m
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	return best.q
+}
+
+// negotiate returns the media type of offers with the highest quality
+// for the Accept header of r, the first offer wins the ties. It returns
+// the first offer when the header is missing or invalid, or an empty
+// string when no offer is acceptable.
+func negotiate(r *http.Request, offers []string) string {
+	ranges := parseAccept(strings.Join(r.Header["Accept"], ","))
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, o := range offers {
+		if q := quality(ranges, o); q > bestQ {
+			best, bestQ = o, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,732 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// apiParam is a query or header parameter of an operation.
+type apiParam struct {
+	name string
+	// in is either query or header.
+	in string
+	// typ is the JSON schema type of the parameter.
+	typ  string
+	desc string
+}
+
+// apiContent is a request or response body, the type of value is the
+// schema of the JSON based media types. A nil value is sent as is, e.g.
+// HTML.
+type apiContent struct {
+	types []string
+	value interface{}
+}
+
+// apiOperation documents a route of the router.
+type apiOperation struct {
+	summary  string
+	params   []*apiParam
+	request  *apiContent
+	response *apiContent
+	// errors are the status codes of the errors of the operation.
+	errors []int
+}
+
+// The parameters shared by several operations.
+var (
+	fieldsParam      = &apiParam{"fields", "query", "string", "comma separated list of the fields to return"}
+	langParam        = &apiParam{"lang", "query", "string", "language of the article, takes precedence over Accept-Language"}
+	limitParam       = &apiParam{"limit", "query", "integer", "maximum number of articles"}
+	offsetParam      = &apiParam{"offset", "query", "integer", "number of articles to skip"}
+	viewParam        = &apiParam{"view", "query", "string", "full or summary, summary sends the summaries instead of the contents"}
+	acceptLangParam  = &apiParam{"Accept-Language", "header", "string", "send the best matching translation"}
+	ifMatchParam     = &apiParam{"If-Match", "header", "string", "only proceed if the article still has this ETag"}
+	ifNoneMatchParam = &apiParam{"If-None-Match", "header", "string", "reply 304 Not Modified if the ETag matches"}
+	idempotencyParam = &apiParam{"Idempotency-Key", "header", "string", "send the first response again to the retries with the same key"}
+)
+
+// filterParams restrict the articles of a listing.
+var filterParams = []*apiParam{
+	{"tag", "query", "string", "only the articles with this tag"},
+	{"category", "query", "string", "only the articles of this category and its subcategories"},
+	{"status", "query", "string", "published, draft or all, defaults to published, draft and all require credentials"},
+	{"since", "query", "string", "only the articles created at or after this RFC 3339 time"},
+	{"until", "query", "string", "only the articles created before this RFC 3339 time"},
+}
+
+// listParams are the parameters of the listings of articles.
+var listParams = append([]*apiParam{
+	limitParam,
+	offsetParam,
+	fieldsParam,
+	viewParam,
+	{"cursor", "query", "string", "paginate with cursors, empty for the first page"},
+}, filterParams...)
+
+// The bodies shared by several operations.
+var (
+	articleContent   = &apiContent{singleTypes, &article{}}
+	articlesContent  = &apiContent{codecTypes, []*article{}}
+	listContent      = &apiContent{listTypes, []*article{}}
+	htmlContent      = &apiContent{[]string{htmlType}, nil}
+	reactionsContent = &apiContent{codecTypes, &reactionState{}}
+)
+
+// getArticleOperation documents the routes sending an article.
+var getArticleOperation = &apiOperation{
+	summary:  "Get an article",
+	params:   []*apiParam{fieldsParam, langParam, acceptLangParam, ifNoneMatchParam},
+	response: articleContent,
+	errors:   []int{400, 404, 406, 500},
+}
+
+// apiOperations documents the routes by method and path template. The
+// routes left out are listed with their path parameters only.
+var apiOperations = map[string]*apiOperation{
+	"GET /article/{id}/{title}/": getArticleOperation,
+	"PUT /article/{id}/{title}/": {
+		summary:  "Replace an article",
+		params:   []*apiParam{ifMatchParam},
+		request:  &apiContent{codecTypes, &article{}},
+		response: articleContent,
+		errors:   []int{400, 404, 406, 412, 422, 500},
+	},
+	"PATCH /article/{id}/{title}/": {
+		summary:  "Patch an article with a JSON Merge Patch",
+		params:   []*apiParam{ifMatchParam},
+		request:  &apiContent{[]string{"application/merge-patch+json"}, &article{}},
+		response: articleContent,
+		errors:   []int{400, 404, 406, 409, 412, 422, 500},
+	},
+	"DELETE /article/{id}/{title}/": {
+		summary: "Move an article to the trash",
+		params:  []*apiParam{ifMatchParam},
+		errors:  []int{404, 412, 500},
+	},
+	"POST /article/{id}/{title}/rename": {
+		summary: "Rename an article",
+		params:  []*apiParam{ifMatchParam},
+		request: &apiContent{codecTypes, &struct {
+			Title string `json:"title"`
+		}{}},
+		response: articleContent,
+		errors:   []int{400, 404, 406, 409, 412, 422, 500},
+	},
+	"POST /article/{id}/{title}/publish": {
+		summary:  "Publish a draft",
+		params:   []*apiParam{ifMatchParam},
+		response: articleContent,
+		errors:   []int{404, 406, 412, 500},
+	},
+	"GET /article/{id}/{title}/related": {
+		summary:  "Get the articles similar to an article",
+		params:   append([]*apiParam{limitParam, offsetParam, fieldsParam, viewParam}, filterParams...),
+		response: articlesContent,
+		errors:   []int{400, 404, 406, 500},
+	},
+	"GET /article/{id}/{title}/html": {
+		summary:  "Get the content of an article as HTML",
+		params:   []*apiParam{langParam, acceptLangParam, ifNoneMatchParam},
+		response: htmlContent,
+		errors:   []int{400, 404, 500},
+	},
+	"GET /article/{id}/{title}/translations": {
+		summary: "List the languages of an article",
+		response: &apiContent{codecTypes, &struct {
+			Language     string   `json:"language,omitempty"`
+			Translations []string `json:"translations"`
+		}{}},
+		errors: []int{404, 406, 500},
+	},
+	"POST /article/{id}/{title}/like": {
+		summary:  "Like an article, once per client",
+		response: reactionsContent,
+		errors:   []int{404, 406, 500, 501},
+	},
+	"DELETE /article/{id}/{title}/like": {
+		summary:  "Remove the like of the client from an article",
+		response: reactionsContent,
+		errors:   []int{404, 406, 500, 501},
+	},
+	"GET /article/{id}/{title}/reactions": {
+		summary:  "Get the reactions to an article and the ones of the client",
+		response: reactionsContent,
+		errors:   []int{404, 406, 500, 501},
+	},
+	"POST /article/{id}/{title}/reactions/{reaction}": {
+		summary:  "React to an article with like or an emoji, once per client",
+		response: reactionsContent,
+		errors:   []int{400, 404, 406, 500, 501},
+	},
+	"DELETE /article/{id}/{title}/reactions/{reaction}": {
+		summary:  "Remove a reaction of the client from an article",
+		response: reactionsContent,
+		errors:   []int{400, 404, 406, 500, 501},
+	},
+	"PUT /article/{id}/{title}/translations/{lang}": {
+		summary:  "Create or replace the translation of an article",
+		params:   []*apiParam{ifMatchParam},
+		request:  &apiContent{codecTypes, &translation{}},
+		response: articleContent,
+		errors:   []int{400, 404, 406, 412, 500},
+	},
+	"DELETE /article/{id}/{title}/translations/{lang}": {
+		summary: "Remove the translation of an article",
+		params:  []*apiParam{ifMatchParam},
+		errors:  []int{400, 404, 412, 500},
+	},
+	"POST /article/{id}/": {
+		summary:  "Store an article",
+		params:   []*apiParam{idempotencyParam},
+		request:  &apiContent{articleTypes, &article{}},
+		response: articleContent,
+		errors:   []int{400, 406, 422, 500},
+	},
+	"GET /article/{id}/by-id/{uuid}/": getArticleOperation,
+	"DELETE /article/{id}/by-id/{uuid}/": {
+		summary: "Move an article to the trash",
+		params:  []*apiParam{ifMatchParam},
+		errors:  []int{404, 412, 500},
+	},
+	"GET /article/{id}/slug/{slug}/": getArticleOperation,
+	"GET /articles/{id}/": {
+		summary:  "List the articles of an user",
+		params:   listParams,
+		response: listContent,
+		errors:   []int{400, 404, 406, 500},
+	},
+	"GET /articles/{id}/{sort}": {
+		summary:  "List the articles of an user by time, sort is asc or desc",
+		params:   listParams,
+		response: listContent,
+		errors:   []int{400, 404, 406, 500},
+	},
+	"DELETE /articles/{id}/": {
+		summary: "Move the articles of an user to the trash, all of them without filter",
+		params: []*apiParam{
+			{"before", "query", "string", "only the articles created before this RFC 3339 time"},
+			{"tag", "query", "string", "only the articles with this tag"},
+		},
+		response: &apiContent{codecTypes, &struct {
+			Deleted int `json:"deleted"`
+		}{}},
+		errors: []int{400, 404, 406, 500},
+	},
+	"GET /articles/{id}/stats": {
+		summary:  "Get statistics about the articles of an user",
+		response: &apiContent{codecTypes, &articleStats{}},
+		errors:   []int{404, 406, 500},
+	},
+	"GET /articles/{id}/search": {
+		summary: "Search the articles of an user",
+		params: append([]*apiParam{
+			{"q", "query", "string", "search terms"},
+			limitParam,
+			offsetParam,
+			fieldsParam,
+			viewParam,
+		}, filterParams...),
+		response: &apiContent{codecTypes, []*highlightedArticle{}},
+		errors:   []int{400, 404, 406, 500},
+	},
+	"GET /articles/{id}/tags": {
+		summary:  "Count the articles of an user by tag",
+		response: &apiContent{codecTypes, []*tagCount{}},
+		errors:   []int{404, 406, 500},
+	},
+	"GET /articles/{id}/archive": {
+		summary:  "Count the articles of an user by month",
+		response: &apiContent{codecTypes, []*archiveMonth{}},
+		errors:   []int{404, 406, 500},
+	},
+	"GET /articles/{id}/archive/{year}/{month}": {
+		summary:  "List the articles of an user created during a month",
+		params:   listParams,
+		response: listContent,
+		errors:   []int{400, 404, 406, 500},
+	},
+	"GET /articles/{id}/popular": {
+		summary: "Get the most viewed articles of an user",
+		params: append([]*apiParam{
+			{"window", "query", "string", "number of days of views, e.g. 7d, or all, defaults to 7d"},
+			limitParam, offsetParam, fieldsParam, viewParam,
+		}, filterParams...),
+		response: articlesContent,
+		errors:   []int{400, 404, 406, 500, 501},
+	},
+	"POST /articles/{id}/batch": {
+		summary:  "Store several articles at once",
+		params:   []*apiParam{idempotencyParam},
+		request:  &apiContent{codecTypes, []*article{}},
+		response: &apiContent{codecTypes, []*batchStatus{}},
+		errors:   []int{400, 406, 422, 500},
+	},
+	"GET /categories/{id}/": {
+		summary:  "List the categories of an user",
+		response: &apiContent{codecTypes, []*category{}},
+		errors:   []int{406, 500},
+	},
+	"POST /categories/{id}/": {
+		summary:  "Create or replace a category",
+		request:  &apiContent{codecTypes, &category{}},
+		response: &apiContent{codecTypes, &category{}},
+		errors:   []int{400, 406, 500},
+	},
+	"DELETE /categories/{id}/{path}": {
+		summary: "Remove a category without subcategories nor articles",
+		errors:  []int{404, 409, 500},
+	},
+	"GET /feed/{id}/rss.xml": {
+		summary:  "Get the RSS feed of an user",
+		params:   []*apiParam{{"limit", "query", "integer", "number of articles"}},
+		response: &apiContent{[]string{"application/rss+xml"}, nil},
+		errors:   []int{400, 404, 500},
+	},
+	"GET /feed/{id}/atom.xml": {
+		summary:  "Get the Atom feed of an user",
+		params:   []*apiParam{{"limit", "query", "integer", "number of articles"}},
+		response: &apiContent{[]string{"application/atom+xml"}, nil},
+		errors:   []int{400, 404, 500},
+	},
+	"GET /feed/{id}/feed.json": {
+		summary:  "Get the JSON Feed of an user",
+		params:   []*apiParam{{"limit", "query", "integer", "number of articles"}},
+		response: &apiContent{[]string{"application/feed+json"}, &jsonFeed{}},
+		errors:   []int{400, 404, 500},
+	},
+	"GET /blog/{id}/": {
+		summary: "Get the blog of an user as HTML",
+		params: []*apiParam{
+			{"page", "query", "integer", "page number"},
+			{"tag", "query", "string", "only the articles with this tag"},
+		},
+		response: htmlContent,
+		errors:   []int{400, 404, 500},
+	},
+	"GET /blog/{id}/{slug}": {
+		summary:  "Get an article of the blog of an user as HTML",
+		params:   []*apiParam{langParam, acceptLangParam},
+		response: htmlContent,
+		errors:   []int{400, 404, 500},
+	},
+	"GET /trash/{id}/": {
+		summary:  "List the deleted articles of an user",
+		response: articlesContent,
+		errors:   []int{404, 406, 500},
+	},
+	"POST /trash/{id}/{title}/restore": {
+		summary: "Restore the deleted article with the title deleted last",
+		errors:  []int{404, 409, 500},
+	},
+	"POST /trash/{id}/by-id/{uuid}/restore": {
+		summary: "Restore a deleted article from its UUID",
+		errors:  []int{404, 409, 500},
+	},
+	"GET /admin/backup": {
+		summary:  "Download a copy of the database",
+		response: &apiContent{[]string{"application/octet-stream"}, nil},
+		errors:   []int{501},
+	},
+	"POST /admin/restore": {
+		summary: "Replace the database with a backup",
+		request: &apiContent{[]string{"application/octet-stream"}, nil},
+		response: &apiContent{codecTypes, &struct {
+			Size int64 `json:"size"`
+		}{}},
+		errors: []int{400, 406, 500, 501},
+	},
+	"POST /admin/compact": {
+		summary: "Compact the database",
+		response: &apiContent{codecTypes, &struct {
+			Before    int64 `json:"before"`
+			After     int64 `json:"after"`
+			Reclaimed int64 `json:"reclaimed"`
+		}{}},
+		errors: []int{406, 500, 501},
+	},
+	"GET /admin/export": {
+		summary:  "Export the articles of all users as ndjson",
+		response: &apiContent{[]string{ndjsonType}, nil},
+	},
+	"POST /admin/import": {
+		summary: "Import articles exported as ndjson",
+		request: &apiContent{[]string{ndjsonType}, nil},
+		response: &apiContent{codecTypes, &struct {
+			Imported int `json:"imported"`
+		}{}},
+		errors: []int{400, 406},
+	},
+	"POST /admin/verify": {
+		summary:  "Check the records of the database",
+		params:   []*apiParam{{"quarantine", "query", "boolean", "move the corrupt records aside"}},
+		response: &apiContent{codecTypes, []*corruptRecord{}},
+		errors:   []int{406, 500, 501},
+	},
+	"GET /admin/loglevel": {
+		summary:  "Get the level of the logs",
+		response: &apiContent{codecTypes, &logLevelBody{}},
+		errors:   []int{406},
+	},
+	"PUT /admin/loglevel": {
+		summary:  "Change the level of the logs until the server restarts",
+		request:  &apiContent{codecTypes, &logLevelBody{}},
+		response: &apiContent{codecTypes, &logLevelBody{}},
+		errors:   []int{400, 406, 415},
+	},
+	"GET /admin/maintenance": {
+		summary:  "Get the state of the maintenance mode",
+		response: &apiContent{codecTypes, &maintenanceBody{}},
+		errors:   []int{406},
+	},
+	"POST /admin/maintenance": {
+		summary:  "Enable or disable the maintenance mode until the server restarts",
+		request:  &apiContent{codecTypes, &maintenanceBody{}},
+		response: &apiContent{codecTypes, &maintenanceBody{}},
+		errors:   []int{400, 406, 415},
+	},
+	"GET /admin/dbstats": {
+		summary:  "Get the statistics of the database",
+		response: &apiContent{codecTypes, &dbStats{}},
+		errors:   []int{406, 500, 501},
+	},
+	"GET /admin/keys": {
+		summary:  "List the API keys",
+		response: &apiContent{codecTypes, []*apiKey{}},
+		errors:   []int{406, 500, 501},
+	},
+	"POST /admin/keys": {
+		summary: "Create an API key",
+		request: &apiContent{codecTypes, &struct {
+			Name string `json:"name"`
+			Role string `json:"role"`
+			User string `json:"user"`
+		}{}},
+		response: &apiContent{codecTypes, &struct {
+			*apiKey
+			Key string `json:"key"`
+		}{}},
+		errors: []int{400, 406, 500, 501},
+	},
+	"DELETE /admin/keys/{key}": {
+		summary: "Revoke an API key",
+		errors:  []int{404, 500, 501},
+	},
+	"GET /docs": {
+		summary:  "Get the API explorer",
+		response: htmlContent,
+	},
+	"GET /docs/": {
+		summary:  "Get the API explorer",
+		response: htmlContent,
+	},
+	"GET /login": {
+		summary:  "Get the login form",
+		params:   []*apiParam{{"next", "query", "string", "path to go to once logged in"}},
+		response: htmlContent,
+		errors:   []int{404},
+	},
+	"POST /login": {
+		summary: "Open a session with a key or a token",
+		request: &apiContent{[]string{"application/x-www-form-urlencoded"}, nil},
+		errors:  []int{401, 403, 404},
+	},
+	"POST /logout": {
+		summary: "End the session",
+		request: &apiContent{[]string{"application/x-www-form-urlencoded"}, nil},
+		errors:  []int{403, 404},
+	},
+	"GET /healthz": {
+		summary:  "Check that the server is alive",
+		response: &apiContent{[]string{"application/json"}, &healthStatus{}},
+	},
+	"GET /readyz": {
+		summary:  "Check that the server can serve the requests, 503 when not",
+		response: &apiContent{[]string{"application/json"}, &healthStatus{}},
+	},
+	"GET /version": {
+		summary:  "Get the version of the server",
+		response: &apiContent{[]string{"application/json"}, &buildInfo{}},
+	},
+	"GET /metrics": {
+		summary:  "Get the Prometheus metrics",
+		response: &apiContent{[]string{"text/plain"}, nil},
+	},
+	"GET /openapi.json": {
+		summary:  "Get this document",
+		response: &apiContent{codecTypes, map[string]interface{}{}},
+		errors:   []int{406},
+	},
+}
+
+// pathVarPattern matches the variables of a path template, with their
+// optional pattern, e.g. {path:.+}.
+var pathVarPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]+)?\}`)
+
+// openAPIDocument returns the OpenAPI 3 description of the routes of
+// router. The paths are read from the router for the document to list
+// every route.
+func openAPIDocument(router *mux.Router) (map[string]interface{}, error) {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil || strings.HasPrefix(tmpl, "/debug/") {
+			// The debug endpoints are not part of the API.
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// The routes without methods are fallbacks.
+			return nil
+		}
+		path := pathVarPattern.ReplaceAllString(tmpl, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		for _, method := range methods {
+			op := apiOperations[method+" "+path]
+			if op == nil {
+				op = &apiOperation{}
+			}
+			doc := op.document(path, schemas)
+			public := path == "/login" || path == "/logout" || reactionPath(path)
+			if (method != "GET" || strings.HasPrefix(path, "/admin/") || path == "/metrics" || trashPath(path)) && !public {
+				// The credentials are only checked when the server
+				// has an admin key or a JWT key.
+				doc["security"] = []interface{}{
+					map[string]interface{}{"bearer": []string{}},
+					map[string]interface{}{"apiKey": []string{}},
+				}
+				responses := doc["responses"].(map[string]interface{})
+				responses["401"] = map[string]interface{}{"$ref": "#/components/responses/Error"}
+				responses["403"] = map[string]interface{}{"$ref": "#/components/responses/Error"}
+			}
+			paths[path][strings.ToLower(method)] = doc
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Blog API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "error as plain text",
+					"content": map[string]interface{}{
+						"text/plain": map[string]interface{}{
+							"schema": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+				"ValidationError": map[string]interface{}{
+					"description": "invalid fields",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"errors": map[string]interface{}{
+										"type": "array",
+										"items": map[string]interface{}{
+											"type": "object",
+											"properties": map[string]interface{}{
+												"field":   map[string]interface{}{"type": "string"},
+												"message": map[string]interface{}{"type": "string"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+				"apiKey": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "X-API-Key",
+				},
+			},
+		},
+	}, nil
+}
+
+// document returns the OpenAPI operation object of op for the route
+// path, the schemas of the bodies are added to schemas.
+func (op *apiOperation) document(path string, schemas map[string]interface{}) map[string]interface{} {
+	var params []interface{}
+	for _, m := range pathVarPattern.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range op.params {
+		params = append(params, map[string]interface{}{
+			"name":        p.name,
+			"in":          p.in,
+			"description": p.desc,
+			"schema":      map[string]interface{}{"type": p.typ},
+		})
+	}
+	ok := map[string]interface{}{"description": "success"}
+	if op.response != nil {
+		ok["content"] = op.response.document(schemas)
+	}
+	responses := map[string]interface{}{"200": ok}
+	for _, code := range op.errors {
+		ref := "#/components/responses/Error"
+		if code == http.StatusUnprocessableEntity {
+			ref = "#/components/responses/ValidationError"
+		}
+		responses[strconv.Itoa(code)] = map[string]interface{}{"$ref": ref}
+	}
+	doc := map[string]interface{}{
+		"responses": responses,
+	}
+	if op.summary != "" {
+		doc["summary"] = op.summary
+	}
+	if params != nil {
+		doc["parameters"] = params
+	}
+	if op.request != nil {
+		doc["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  op.request.document(schemas),
+		}
+	}
+	return doc
+}
+
+// document returns the OpenAPI content object of c.
+func (c *apiContent) document(schemas map[string]interface{}) map[string]interface{} {
+	content := make(map[string]interface{}, len(c.types))
+	for _, t := range c.types {
+		var schema interface{}
+		switch {
+		case c.value == nil || t == protobufType || t == xmlType:
+			schema = map[string]interface{}{"type": "string"}
+		case codecOf(t) != nil || t == "application/merge-patch+json" || t == "application/feed+json":
+			schema = schemaOf(reflect.TypeOf(c.value), schemas)
+		default:
+			// JSON:API and HAL wrap the articles.
+			schema = map[string]interface{}{"type": "object"}
+		}
+		content[t] = map[string]interface{}{"schema": schema}
+	}
+	return content
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the JSON schema of the JSON encoding of the type t.
+// The named structs are added to schemas and referenced.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case t.Kind() != reflect.Struct:
+		return map[string]interface{}{}
+	}
+	if t.Name() == "" {
+		return structSchema(t, schemas)
+	}
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + string(name)}
+	if _, ok := schemas[string(name)]; !ok {
+		// The schema is registered before being built for the recursive
+		// types to terminate.
+		schemas[string(name)] = nil
+		schemas[string(name)] = structSchema(t, schemas)
+	}
+	return ref
+}
+
+// structSchema returns the JSON schema of the struct type t. No field
+// is required, the same schemas describe the requests where the server
+// fills some fields.
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("json"), ",")
+			if f.Anonymous && tag[0] == "" {
+				// The fields of the embedded structs are inlined.
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				addFields(ft)
+				continue
+			}
+			if f.PkgPath != "" || tag[0] == "-" {
+				continue
+			}
+			name := tag[0]
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = schemaOf(f.Type, schemas)
+		}
+	}
+	addFields(t)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// openAPIHandler sends the OpenAPI 3 description of the API.
+func (s *server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := openAPIDocument(s.mux)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if p := basePathOf(r.Context()); p != "" {
+		doc["servers"] = []map[string]interface{}{{"url": p}}
+	}
+	writeResponse(w, r, doc)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// invalidError is returned when a client sends invalid data.
+type invalidError string
+
+func (e invalidError) Error() string {
+	return string(e)
+}
+
+// mergePatch applies the JSON Merge Patch patch to doc, as described by
+// RFC 7386.
+func mergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergePatch(d[k], v)
+	}
+	return d
+}
+
+// patchArticle applies the JSON Merge Patch patch to a. The fields
+// owned by the server cannot be patched.
+func patchArticle(a *article, patch interface{}) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+	data, err = json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return err
+	}
+	patched := &article{}
+	err = json.Unmarshal(data, patched)
+	if err != nil {
+		return invalidError("patch produces an invalid article")
+	}
+	err = validateArticle(patched)
+	if err != nil {
+		return err
+	}
+	sanitizeArticle(patched)
+	a.Tags, err = normalizeTags(patched.Tags)
+	if err != nil {
+		return err
+	}
+	a.Category, err = normalizeCategory(patched.Category)
+	if err != nil {
+		return err
+	}
+	a.Metadata, err = normalizeMetadata(patched.Metadata)
+	if err != nil {
+		return err
+	}
+	lang, err := normalizeLanguage(patched.Language)
+	if err != nil {
+		return err
+	}
+	translations, err := normalizeTranslations(patched.Translations)
+	if err != nil {
+		return err
+	}
+	if translations[lang] != nil {
+		return invalidError("translation in the language of the article")
+	}
+	if patched.Status == "" {
+		patched.Status = statusPublished
+	}
+	err = checkStatus(patched.Status)
+	if err != nil {
+		return err
+	}
+	a.Status = patched.Status
+	a.PublishAt = patched.PublishAt
+	a.Title = patched.Title
+	a.Content = patched.Content
+	a.Summary = patched.Summary
+	a.ExpiresAt = patched.ExpiresAt
+	a.Language = lang
+	a.Translations = translations
+	return nil
+}
+
+func (s *server) patchArticleHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/merge-patch+json" {
+		writeError(w, http.StatusBadRequest, "invalid content-type")
+		return
+	}
+
+	var patch interface{}
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "fail to parse JSON")
+		return
+	}
+	// The category is checked before the update, the store cannot be
+	// used while updating.
+	if p, ok := patch.(map[string]interface{}); ok {
+		if c, ok := p["category"].(string); ok {
+			c, err = normalizeCategory(c)
+			if err == nil {
+				err = s.checkCategory(id, c)
+			}
+			if e, ok := err.(invalidError); ok {
+				writeError(w, http.StatusBadRequest, e.Error())
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+				writeError(w, http.StatusInternalServerError, "fail to access DB")
+				return
+			}
+		}
+	}
+
+	now := time.Now()
+	a, err := s.storeOf(r).Update(id, title, func(a *article) error {
+		err := checkIfMatch(r, a)
+		if err != nil {
+			return err
+		}
+		err = patchArticle(a, patch)
+		if err != nil {
+			return err
+		}
+		if a.expired(now) {
+			return invalidError("expires_at is in the past")
+		}
+		a.schedule(now)
+		a.Updated = &now
+		return nil
+	})
+	if e, ok := err.(validationError); ok {
+		writeValidationError(w, r, e)
+		return
+	}
+	if e, ok := err.(invalidError); ok {
+		writeError(w, http.StatusBadRequest, e.Error())
+		return
+	}
+	if err == errUnknownID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errTitleExists {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err == errPreconditionFailed {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	w.Header().Set("ETag", articleETag(a))
+	writeArticle(w, r, a, nil)
+}
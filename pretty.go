@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// prettyType can be accepted instead of application/json to receive
+// indented JSON.
+const prettyType = "application/json+pretty"
+
+// prettyMiddleware indents the JSON responses when the request has the
+// pretty query parameter set or accepts prettyType. The other responses
+// are sent unchanged.
+func prettyMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pretty := false
+		if v := r.URL.Query().Get("pretty"); v != "" {
+			var err error
+			pretty, err = strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid pretty parameter")
+				return
+			}
+		}
+		accept := r.Header.Get("Accept")
+		if strings.Contains(accept, prettyType) {
+			pretty = true
+			// The handlers only know application/json.
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.Header = make(http.Header, len(r.Header))
+			for k, v := range r.Header {
+				r2.Header[k] = v
+			}
+			r2.Header.Set("Accept", strings.Replace(accept, prettyType, jsonCodec.mediaTypes[0], -1))
+			r = r2
+		}
+		if !pretty {
+			h.ServeHTTP(w, r)
+			return
+		}
+		pw := &prettyWriter{ResponseWriter: w}
+		h.ServeHTTP(pw, r)
+		pw.flush()
+	})
+}
+
+// prettyWriter buffers the JSON responses to indent them, the other
+// responses are written through.
+type prettyWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	code        int
+	// buf is set when the response is JSON.
+	buf *bytes.Buffer
+}
+
+func (w *prettyWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if isJSONType(w.Header().Get("Content-Type")) {
+		w.code = code
+		w.buf = &bytes.Buffer{}
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *prettyWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buf != nil {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped ResponseWriter, letting the handlers
+// control the connection through it.
+func (w *prettyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flush writes the indented JSON response.
+func (w *prettyWriter) flush() {
+	if w.buf == nil {
+		return
+	}
+	out := &bytes.Buffer{}
+	err := json.Indent(out, w.buf.Bytes(), "", "  ")
+	if err != nil {
+		// Not a single JSON document, e.g. an empty body.
+		out = w.buf
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.code)
+	w.ResponseWriter.Write(out.Bytes())
+}
+
+// isJSONType reports whether the media type of contentType is JSON,
+// including the JSON based types like application/hal+json.
+func isJSONType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
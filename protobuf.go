@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protobufType is the media type of the Protocol Buffers encoding of
+// the articles, described by blog.proto.
+const protobufType = "application/x-protobuf"
+
+// The field numbers of the Article message.
+const (
+	pbUUID      = 1
+	pbTitle     = 2
+	pbSlug      = 3
+	pbContent   = 4
+	pbSummary   = 5
+	pbStatus    = 6
+	pbPublishAt = 7
+	pbCategory  = 8
+	pbTags      = 9
+	pbMetadata  = 10
+	pbTimestamp = 11
+	pbUpdated   = 12
+	pbExpiresAt = 13
+	pbDeleted   = 14
+	pbLanguage  = 15
+	// pbTranslations is a map of Translation messages, which have the
+	// title, the content and the summary as fields 1, 2 and 3.
+	pbTranslations = 16
+	pbReactions    = 17
+	pbViews        = 18
+)
+
+var errInvalidProtobuf = errors.New("invalid protobuf message")
+
+// appendString appends a string field, empty strings are left out as
+// in proto3.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendTime appends a google.protobuf.Timestamp field.
+func appendTime(b []byte, num protowire.Number, t *time.Time) []byte {
+	if t == nil || t.IsZero() {
+		return b
+	}
+	var ts []byte
+	if s := t.Unix(); s != 0 {
+		ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(s))
+	}
+	if ns := t.Nanosecond(); ns != 0 {
+		ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(ns))
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, ts)
+}
+
+// marshalArticle returns the Article message of a.
+func marshalArticle(a *article) []byte {
+	var b []byte
+	b = appendString(b, pbUUID, a.UUID)
+	b = appendString(b, pbTitle, a.Title)
+	b = appendString(b, pbSlug, a.Slug)
+	b = appendString(b, pbContent, a.Content)
+	b = appendString(b, pbSummary, a.Summary)
+	b = appendString(b, pbStatus, a.Status)
+	b = appendTime(b, pbPublishAt, a.PublishAt)
+	b = appendString(b, pbCategory, a.Category)
+	for _, t := range a.Tags {
+		b = protowire.AppendTag(b, pbTags, protowire.BytesType)
+		b = protowire.AppendString(b, t)
+	}
+	// The map entries are sorted for the encoding to be stable.
+	keys := make([]string, 0, len(a.Metadata))
+	for k := range a.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, a.Metadata[k])
+		b = protowire.AppendTag(b, pbMetadata, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	b = appendTime(b, pbTimestamp, &a.Timestamp)
+	b = appendTime(b, pbUpdated, a.Updated)
+	b = appendTime(b, pbExpiresAt, a.ExpiresAt)
+	b = appendTime(b, pbDeleted, a.Deleted)
+	b = appendString(b, pbLanguage, a.Language)
+	for _, lang := range a.Translations.languages() {
+		t := a.Translations[lang]
+		var value []byte
+		value = appendString(value, 1, t.Title)
+		value = appendString(value, 2, t.Content)
+		value = appendString(value, 3, t.Summary)
+		var entry []byte
+		entry = appendString(entry, 1, lang)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, value)
+		b = protowire.AppendTag(b, pbTranslations, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	names := make([]string, 0, len(a.Reactions))
+	for name := range a.Reactions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var entry []byte
+		entry = appendString(entry, 1, name)
+		entry = protowire.AppendTag(entry, 2, protowire.VarintType)
+		entry = protowire.AppendVarint(entry, uint64(a.Reactions[name]))
+		b = protowire.AppendTag(b, pbReactions, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	if a.Views != 0 {
+		b = protowire.AppendTag(b, pbViews, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(a.Views))
+	}
+	return b
+}
+
+// marshalArticleList returns the ArticleList message of articles.
+func marshalArticleList(articles []*article) []byte {
+	var b []byte
+	for _, a := range articles {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalArticle(a))
+	}
+	return b
+}
+
+// consumeFields calls fn for each field of the message b, fn returns
+// the length of the value it consumed or a negative length when the
+// value is invalid. The unknown fields are skipped.
+func consumeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errInvalidProtobuf
+		}
+		b = b[n:]
+		n = fn(num, typ, b)
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errInvalidProtobuf
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// consumeString consumes a string value into s.
+func consumeString(typ protowire.Type, b []byte, s *string) int {
+	if typ != protowire.BytesType {
+		return -1
+	}
+	v, n := protowire.ConsumeString(b)
+	*s = v
+	return n
+}
+
+// consumeTime consumes a google.protobuf.Timestamp value into t.
+func consumeTime(typ protowire.Type, b []byte, t *time.Time) int {
+	if typ != protowire.BytesType {
+		return -1
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n
+	}
+	var seconds, nanos uint64
+	err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if typ != protowire.VarintType || (num != 1 && num != 2) {
+			return 0
+		}
+		x, n := protowire.ConsumeVarint(b)
+		if num == 1 {
+			seconds = x
+		} else {
+			nanos = x
+		}
+		return n
+	})
+	if err != nil {
+		return -1
+	}
+	*t = time.Unix(int64(seconds), int64(int32(nanos))).UTC()
+	return n
+}
+
+// consumeTimePtr consumes a google.protobuf.Timestamp value into *p.
+func consumeTimePtr(typ protowire.Type, b []byte, p **time.Time) int {
+	t := &time.Time{}
+	n := consumeTime(typ, b, t)
+	if n > 0 {
+		*p = t
+	}
+	return n
+}
+
+// unmarshalArticle decodes the Article message b into a.
+func unmarshalArticle(b []byte, a *article) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case pbUUID:
+			return consumeString(typ, b, &a.UUID)
+		case pbTitle:
+			return consumeString(typ, b, &a.Title)
+		case pbSlug:
+			return consumeString(typ, b, &a.Slug)
+		case pbContent:
+			return consumeString(typ, b, &a.Content)
+		case pbSummary:
+			return consumeString(typ, b, &a.Summary)
+		case pbStatus:
+			return consumeString(typ, b, &a.Status)
+		case pbPublishAt:
+			return consumeTimePtr(typ, b, &a.PublishAt)
+		case pbCategory:
+			return consumeString(typ, b, &a.Category)
+		case pbTags:
+			var t string
+			n := consumeString(typ, b, &t)
+			a.Tags = append(a.Tags, t)
+			return n
+		case pbMetadata:
+			if typ != protowire.BytesType {
+				return -1
+			}
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n
+			}
+			var key, value string
+			err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) int {
+				switch num {
+				case 1:
+					return consumeString(typ, b, &key)
+				case 2:
+					return consumeString(typ, b, &value)
+				}
+				return 0
+			})
+			if err != nil {
+				return -1
+			}
+			if a.Metadata == nil {
+				a.Metadata = make(metadata)
+			}
+			a.Metadata[key] = value
+			return n
+		case pbTimestamp:
+			return consumeTime(typ, b, &a.Timestamp)
+		case pbUpdated:
+			return consumeTimePtr(typ, b, &a.Updated)
+		case pbExpiresAt:
+			return consumeTimePtr(typ, b, &a.ExpiresAt)
+		case pbDeleted:
+			return consumeTimePtr(typ, b, &a.Deleted)
+		case pbLanguage:
+			return consumeString(typ, b, &a.Language)
+		case pbTranslations:
+			if typ != protowire.BytesType {
+				return -1
+			}
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n
+			}
+			var lang string
+			t := &translation{}
+			err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) int {
+				switch num {
+				case 1:
+					return consumeString(typ, b, &lang)
+				case 2:
+					return consumeTranslation(typ, b, t)
+				}
+				return 0
+			})
+			if err != nil {
+				return -1
+			}
+			if a.Translations == nil {
+				a.Translations = make(translations)
+			}
+			a.Translations[lang] = t
+			return n
+		}
+		return 0
+	})
+}
+
+// consumeTranslation consumes a Translation message into t.
+func consumeTranslation(typ protowire.Type, b []byte, t *translation) int {
+	if typ != protowire.BytesType {
+		return -1
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n
+	}
+	err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &t.Title)
+		case 2:
+			return consumeString(typ, b, &t.Content)
+		case 3:
+			return consumeString(typ, b, &t.Summary)
+		}
+		return 0
+	})
+	if err != nil {
+		return -1
+	}
+	return n
+}
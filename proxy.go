@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies are the reverse proxies whose forwarding headers are
+// trusted.
+type trustedProxies struct {
+	networks []*net.IPNet
+	// unix trusts the clients of the unix socket, the proxy of the
+	// host.
+	unix bool
+}
+
+// parseTrustedProxies parses the networks of list, unix stands for the
+// clients of the unix socket.
+func parseTrustedProxies(list []string) (*trustedProxies, error) {
+	t := &trustedProxies{}
+	var cidrs []string
+	for _, v := range list {
+		if v == "unix" {
+			t.unix = true
+			continue
+		}
+		cidrs = append(cidrs, v)
+	}
+	var err error
+	t.networks, err = parseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.networks) == 0 && !t.unix {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// trusts reports whether the client at addr is a trusted proxy.
+func (t *trustedProxies) trusts(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		// The clients of a unix socket have no IP.
+		return t.unix
+	}
+	return containsIP(t.networks, ip)
+}
+
+// forwardedFor returns the IP of the client a trusted proxy forwarded r
+// for, empty when it did not tell. X-Forwarded-For is read from the
+// right, the first IP not trusted being the client, as the client can
+// send one with any IP.
+func (t *trustedProxies) forwardedFor(r *http.Request) string {
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			return ""
+		}
+		if i == 0 || !t.trusts(hop) {
+			return hop
+		}
+	}
+	ip := strings.TrimSpace(r.Header.Get("X-Real-IP"))
+	if net.ParseIP(ip) == nil {
+		return ""
+	}
+	return ip
+}
+
+// schemeKey is the context key of the scheme forwarded by a proxy.
+type schemeKey struct{}
+
+// requestScheme returns the scheme the client sent r with, http or
+// https.
+func requestScheme(r *http.Request) string {
+	if scheme, ok := r.Context().Value(schemeKey{}).(string); ok {
+		return scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// proxyMiddleware replaces the address of the requests sent by the
+// trusted proxies with the one of their client, so that the logs, the
+// rate limits and the IP filter see the client, and keeps the scheme
+// of the client for the absolute links.
+func proxyMiddleware(h http.Handler, t *trustedProxies) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !t.trusts(r.RemoteAddr) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		r2 := r
+		if ip := t.forwardedFor(r); ip != "" {
+			r2 = r.WithContext(r.Context())
+			r2.RemoteAddr = ip
+		}
+		switch proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto {
+		case "http", "https":
+			r2 = r2.WithContext(context.WithValue(r2.Context(), schemeKey{}, proto))
+		}
+		h.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ulule/limiter"
+)
+
+// rateLimits limits the requests of each client by class: the reads,
+// the writes and the admin requests have their own rate.
+type rateLimits struct {
+	read  *limiter.Limiter
+	write *limiter.Limiter
+	admin *limiter.Limiter
+	// auth identifies the clients sending credentials, the others are
+	// identified by their IP. Nil when the authentication is disabled.
+	auth *authenticator
+}
+
+// newRateLimits returns the limits configured by cfg, sharing a memory
+// store. A class whose rate is zero is not limited.
+func newRateLimits(cfg *config, auth *authenticator) *rateLimits {
+	store := limiter.NewMemoryStore()
+	newLimiter := func(rate limiter.Rate) *limiter.Limiter {
+		if rate.Limit == 0 {
+			return nil
+		}
+		return limiter.NewLimiter(store, rate)
+	}
+	return &rateLimits{
+		read:  newLimiter(cfg.RateRead),
+		write: newLimiter(cfg.RateWrite),
+		admin: newLimiter(cfg.RateAdmin),
+		auth:  auth,
+	}
+}
+
+// limiterOf returns the class of r and its limiter.
+func (l *rateLimits) limiterOf(r *http.Request) (string, *limiter.Limiter) {
+	switch {
+	case adminPath(r.URL.Path):
+		return "admin", l.admin
+	case r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS":
+		return "read", l.read
+	}
+	return "write", l.write
+}
+
+// clientKey returns who sent r: the admin, the user of a token or an
+// API key when r carries valid credentials checked by a, else its IP.
+// The clients sharing an IP are limited separately once authenticated,
+// and the requests with invalid credentials count for their IP.
+func clientKey(r *http.Request, a *authenticator) (*http.Request, string) {
+	if a != nil {
+		var who *identity
+		r, who, _ = a.authenticate(r)
+		switch {
+		case who == nil:
+		case who.admin:
+			return r, "admin"
+		case who.user != "":
+			return r, "user:" + who.user
+		case who.keyHash != "":
+			return r, "key:" + who.keyHash
+		}
+	}
+	return r, "ip:" + clientIP(r)
+}
+
+// clientIP returns the IP of the client of r.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitMiddleware rejects with 429 the requests of the clients over
+// the rate of their class, see clientKey. The responses tell the rate,
+// the requests left and when the period ends in the X-RateLimit headers.
+func rateLimitMiddleware(h http.Handler, l *rateLimits) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class, lim := l.limiterOf(r)
+		if lim == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		r, client := clientKey(r, l.auth)
+		ctx, err := lim.Get(class + ":" + client)
+		if err != nil {
+			// The requests are not blocked by a failing store.
+			slog.ErrorContext(r.Context(), "fail to limit rate", "err", err)
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(ctx.Limit, 10))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(ctx.Remaining, 10))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(ctx.Reset, 10))
+		if ctx.Reached {
+			wait := ctx.Reset - time.Now().Unix()
+			if wait < 1 {
+				wait = 1
+			}
+			w.Header().Set("Retry-After", strconv.FormatInt(wait, 10))
+			slog.DebugContext(r.Context(), "rate limit exceeded", "class", class, "client", client)
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// parseRate reads a rate written as requests/period, e.g. 264/1m, or 0
+// for no limit.
+func parseRate(s string) (limiter.Rate, error) {
+	if s == "0" {
+		return limiter.Rate{}, nil
+	}
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return limiter.Rate{}, errors.New("missing period")
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil || n <= 0 {
+		return limiter.Rate{}, errors.New("invalid number of requests")
+	}
+	period, err := time.ParseDuration(s[i+1:])
+	if err != nil || period <= 0 {
+		return limiter.Rate{}, errors.New("invalid period")
+	}
+	return limiter.Rate{Period: period, Limit: n}, nil
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+)
+
+// likeReaction is the reaction of POST /article/{id}/{title}/like.
+const likeReaction = "like"
+
+// reactionNames are the reactions the readers may add to an article.
+var reactionNames = []string{likeReaction, "❤️", "😂", "😮", "😢", "🎉"}
+
+// variationSelector asks for the emoji presentation of a character.
+const variationSelector = "\ufe0f"
+
+// reactionsBucket contains a bucket per user ID, each one containing
+// the reaction counts of the user articles keyed by UUID, and the
+// reactions of each client keyed by reactionKey with empty values.
+var reactionsBucket = []byte("_reactions")
+
+// parseReaction returns the name of the reaction s, the variation
+// selector of the emoji is optional.
+func parseReaction(s string) (string, error) {
+	s = strings.Replace(s, variationSelector, "", -1)
+	for _, name := range reactionNames {
+		if s == strings.Replace(name, variationSelector, "", -1) {
+			return name, nil
+		}
+	}
+	return "", invalidError("unknown reaction, expected one of " + strings.Join(reactionNames, " "))
+}
+
+// reactions counts the reactions to an article by name.
+type reactions map[string]int
+
+// add returns a copy of r with delta reactions name, the reactions
+// counted zero are left out.
+func (r reactions) add(name string, delta int) reactions {
+	c := make(reactions, len(r)+1)
+	for k, v := range r {
+		c[k] = v
+	}
+	c[name] += delta
+	if c[name] <= 0 {
+		delete(c, name)
+	}
+	return c
+}
+
+// MarshalXML encodes the reactions as a list of entries sorted by name,
+// since maps are not supported by encoding/xml.
+func (r reactions) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(r) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(r))
+	for k := range r {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	for _, k := range names {
+		entry := xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: k}},
+		}
+		err = e.EncodeElement(r[k], entry)
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML skips the reactions, they are counted by the store.
+func (r *reactions) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return d.Skip()
+}
+
+// decodeReactions decodes the reaction counts of an article, nil when
+// the article has none.
+func decodeReactions(data []byte) (reactions, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var r reactions
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// reactionKey returns the key of the reaction of client to the article
+// uuid. The fields are separated by a zero byte, the reactions of a
+// client share the prefix of the key.
+func reactionKey(uuid, client, reaction string) []byte {
+	return []byte(uuid + "\x00" + client + "\x00" + reaction)
+}
+
+// reactionClient returns the identifier of the client of r for the
+// reactions, the hash of its credentials or of its IP.
+func reactionClient(r *http.Request, a *authenticator) string {
+	_, client := clientKey(r, a)
+	sum := sha256.Sum256([]byte(client))
+	return hex.EncodeToString(sum[:16])
+}
+
+// reactionPath reports whether path adds or removes the reaction of a
+// reader to an article, which needs no credentials.
+func reactionPath(path string) bool {
+	parts := strings.Split(path, "/")
+	if len(parts) < 5 || parts[0] != "" || parts[1] != "article" {
+		return false
+	}
+	return (len(parts) == 5 && parts[4] == likeReaction) || (len(parts) == 6 && parts[4] == "reactions")
+}
+
+// reactionState is the body of the reaction endpoints.
+type reactionState struct {
+	// Reactions counts the reactions to the article.
+	Reactions reactions `json:"reactions"`
+	// Reacted are the reactions of the client.
+	Reacted []string `json:"reacted"`
+}
+
+// reactionStore is implemented by the stores counting the reactions of
+// the readers.
+type reactionStore interface {
+	// React adds the reaction of client to the article uuid of user id,
+	// or removes it unless add is set. A client reacts once with each
+	// reaction.
+	React(id, uuid, client, reaction string, add bool) (*reactionState, error)
+	// Reactions returns the reactions to the article uuid of user id.
+	Reactions(id, uuid, client string) (*reactionState, error)
+}
+
+// reactionsHandler serves the reactions of the clients to an article,
+// the reaction is a like when the route has none.
+func (s *server) reactionsHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+	rs, ok := baseStore(s.store).(reactionStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "reactions not supported by the store")
+		return
+	}
+	reaction := likeReaction
+	if v, ok := params["reaction"]; ok {
+		var err error
+		reaction, err = parseReaction(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	a, err := s.storeOf(r).Get(id, title)
+	if err == nil && (a.expired(time.Now()) || s.hidden(r, id, a)) {
+		err = errUnknownTitle
+	}
+	client := reactionClient(r, s.auth)
+	var state *reactionState
+	if err == nil {
+		switch r.Method {
+		case "GET":
+			state, err = rs.Reactions(id, a.UUID, client)
+		case "POST":
+			state, err = rs.React(id, a.UUID, client, reaction, true)
+		default:
+			state, err = rs.React(id, a.UUID, client, reaction, false)
+		}
+	}
+	if err == errUnknownID || err == errUnknownTitle || err == errUnknownUUID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	writeResponse(w, r, state)
+}
+
+// reactionsOf returns the bucket of the reactions of user id, nil when
+// it does not exist.
+func reactionsOf(tx *bolt.Tx, id []byte) *bolt.Bucket {
+	// The bucket is missing while the older migrations are applied.
+	if b := tx.Bucket(reactionsBucket); b != nil {
+		return b.Bucket(id)
+	}
+	return nil
+}
+
+// reactionStateOf returns the reactions to the article uuid of the
+// bucket b and the ones of client.
+func reactionStateOf(b *bolt.Bucket, uuid, client string) (*reactionState, error) {
+	state := &reactionState{Reactions: reactions{}, Reacted: []string{}}
+	if b == nil {
+		return state, nil
+	}
+	counts, err := decodeReactions(b.Get([]byte(uuid)))
+	if err != nil {
+		return nil, err
+	}
+	if counts != nil {
+		state.Reactions = counts
+	}
+	prefix := reactionKey(uuid, client, "")
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		state.Reacted = append(state.Reacted, string(k[len(prefix):]))
+	}
+	return state, nil
+}
+
+// deleteReactions removes the reactions to the article uuid of user id.
+func deleteReactions(tx *bolt.Tx, id []byte, uuid string) error {
+	b := reactionsOf(tx, id)
+	if b == nil {
+		return nil
+	}
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek([]byte(uuid)); k != nil && bytes.HasPrefix(k, []byte(uuid)); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		err := b.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *boltStore) React(id, uuid, client, reaction string, add bool) (*reactionState, error) {
+	var state *reactionState
+	err := s.update(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		if b.articles.Get([]byte(uuid)) == nil {
+			return errUnknownUUID
+		}
+		rb, err := tx.Bucket(reactionsBucket).CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return err
+		}
+		counts, err := decodeReactions(rb.Get([]byte(uuid)))
+		if err != nil {
+			return err
+		}
+		k := reactionKey(uuid, client, reaction)
+		found, _ := rb.Cursor().Seek(k)
+		reacted := bytes.Equal(found, k)
+		switch {
+		case add && !reacted:
+			err = rb.Put(k, nil)
+			counts = counts.add(reaction, 1)
+		case !add && reacted:
+			err = rb.Delete(k)
+			counts = counts.add(reaction, -1)
+		default:
+			state, err = reactionStateOf(rb, uuid, client)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if len(counts) == 0 {
+			err = rb.Delete([]byte(uuid))
+		} else {
+			var data []byte
+			data, err = json.Marshal(counts)
+			if err == nil {
+				err = rb.Put([]byte(uuid), data)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		state, err = reactionStateOf(rb, uuid, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *boltStore) Reactions(id, uuid, client string) (*reactionState, error) {
+	var state *reactionState
+	err := s.view(func(tx *bolt.Tx) error {
+		b, err := buckets(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		if b.articles.Get([]byte(uuid)) == nil {
+			return errUnknownUUID
+		}
+		state, err = reactionStateOf(b.reactions, uuid, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// articleByUUID returns the title and the article uuid of user id,
+// s.mu must be held.
+func (s *memoryStore) articleByUUID(id, uuid string) (string, article, error) {
+	articles, ok := s.users[id]
+	if !ok {
+		return "", article{}, errUnknownID
+	}
+	for title, a := range articles {
+		if a.UUID == uuid {
+			return title, a, nil
+		}
+	}
+	return "", article{}, errUnknownUUID
+}
+
+// reactionState returns the reactions to the article a of user id and
+// the ones of client, s.mu must be held.
+func (s *memoryStore) reactionState(id string, a article, client string) *reactionState {
+	state := &reactionState{Reactions: reactions{}, Reacted: []string{}}
+	for k, v := range a.Reactions {
+		state.Reactions[k] = v
+	}
+	prefix := id + "\x00" + string(reactionKey(a.UUID, client, ""))
+	for k := range s.reactions {
+		if strings.HasPrefix(k, prefix) {
+			state.Reacted = append(state.Reacted, k[len(prefix):])
+		}
+	}
+	sort.Strings(state.Reacted)
+	return state
+}
+
+// deleteReactions removes the reactions to the article uuid of user id,
+// s.mu must be held for writing.
+func (s *memoryStore) deleteReactions(id, uuid string) {
+	prefix := id + "\x00" + uuid + "\x00"
+	for k := range s.reactions {
+		if strings.HasPrefix(k, prefix) {
+			delete(s.reactions, k)
+		}
+	}
+}
+
+func (s *memoryStore) React(id, uuid, client, reaction string, add bool) (*reactionState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	title, a, err := s.articleByUUID(id, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if s.reactions == nil {
+		s.reactions = make(map[string]bool)
+	}
+	k := id + "\x00" + string(reactionKey(uuid, client, reaction))
+	if s.reactions[k] != add {
+		if add {
+			s.reactions[k] = true
+			a.Reactions = a.Reactions.add(reaction, 1)
+		} else {
+			delete(s.reactions, k)
+			a.Reactions = a.Reactions.add(reaction, -1)
+		}
+		if len(a.Reactions) == 0 {
+			a.Reactions = nil
+		}
+		s.users[id][title] = a
+	}
+	return s.reactionState(id, a, client), nil
+}
+
+func (s *memoryStore) Reactions(id, uuid, client string) (*reactionState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, a, err := s.articleByUUID(id, uuid)
+	if err != nil {
+		return nil, err
+	}
+	return s.reactionState(id, a, client), nil
+}
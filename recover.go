@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoveredPanics counts the panics of the handlers, served as 500.
+var recoveredPanics = expvar.NewInt("recovered_panics")
+
+// panicError is the body of the response to a request whose handler
+// panicked.
+type panicError struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// recoverMiddleware serves a 500 JSON error with the ID of the request
+// when its handler panics, and logs the panic with its stack. The
+// connection is closed when the response was already started.
+func recoverMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			recoveredPanics.Add(1)
+			slog.ErrorContext(r.Context(), "handler panicked", "err", fmt.Sprint(v), "stack", string(debug.Stack()))
+			if sw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			data, err := json.Marshal(&panicError{
+				Error:     http.StatusText(http.StatusInternalServerError),
+				RequestID: requestIDOf(r.Context()),
+			})
+			if err != nil {
+				panic(http.ErrAbortHandler)
+			}
+			for _, k := range []string{"Content-Length", "Content-Disposition", "ETag", "Last-Modified"} {
+				w.Header().Del(k)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(data)
+		}()
+		h.ServeHTTP(sw, r)
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// defaultRelated is the number of related articles returned when
+	// no limit is given.
+	defaultRelated = 5
+	// minTermLen is the length under which words are too common to
+	// relate articles.
+	minTermLen = 4
+)
+
+// wordSet returns the set of the lowercase words of a, the short words
+// are left out.
+func wordSet(a *article) map[string]bool {
+	words := make(map[string]bool)
+	split := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+	for _, text := range []string{a.Title, a.Content} {
+		for _, w := range strings.FieldsFunc(strings.ToLower(text), split) {
+			if len([]rune(w)) >= minTermLen {
+				words[w] = true
+			}
+		}
+	}
+	return words
+}
+
+// similarity returns how close b is to a: the number of shared tags
+// plus the ratio of shared words, between 0 and 1. A shared tag thus
+// weighs more than the words.
+func similarity(a *article, words map[string]bool, b *article) float64 {
+	shared := 0
+	for _, t := range b.Tags {
+		if a.hasTag(t) {
+			shared++
+		}
+	}
+	other := wordSet(b)
+	common := 0
+	for w := range other {
+		if words[w] {
+			common++
+		}
+	}
+	score := float64(shared)
+	if union := len(words) + len(other) - common; union > 0 {
+		score += float64(common) / float64(union)
+	}
+	return score
+}
+
+// related returns the articles of user id matching q which are similar
+// to a, the most similar first. Articles as similar are ordered from
+// the newest.
+func related(store Store, id string, a *article, q *listQuery) ([]*article, error) {
+	type result struct {
+		a     *article
+		score float64
+	}
+	words := wordSet(a)
+	var results []result
+	err := store.Walk(id, byTimeDesc, nil, func(b *article) bool {
+		if b.UUID == a.UUID || !q.match(b) {
+			return true
+		}
+		if n := similarity(a, words, b); n > 0 {
+			results = append(results, result{b, n})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	articles := make([]*article, len(results))
+	for i, r := range results {
+		articles[i] = r.a
+	}
+	return articles, nil
+}
+
+func (s *server) relatedArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	q, err := parseListQuery(r, "")
+	if err == nil && q.paged {
+		err = errors.New("cursors are not supported by related articles")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.checkListStatus(w, r, id, q) {
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		q.limit = defaultRelated
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	summary, err := parseView(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a, err := s.storeOf(r).Get(id, title)
+	if err == nil && (a.expired(time.Now()) || s.hidden(r, id, a)) {
+		err = errUnknownTitle
+	}
+	if err == errUnknownID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	articles, err := related(s.storeOf(r), id, a, q)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	if q.offset < len(articles) {
+		articles = articles[q.offset:]
+	} else {
+		articles = nil
+	}
+	if len(articles) > q.limit {
+		articles = articles[:q.limit]
+	}
+	if articles == nil {
+		articles = []*article{}
+	}
+	if summary {
+		articles = summarize(articles)
+	}
+	writeResponse(w, r, jsonArticles(articles, fields))
+}
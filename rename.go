@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// renameArticleHandler changes the title of an article, the article
+// keeps its UUID, slug and content.
+func (s *server) renameArticleHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	in := struct {
+		Title string `json:"title"`
+	}{}
+	err := decodeRequest(r, &in)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if in.Title == "" {
+		writeError(w, http.StatusBadRequest, "missing new title")
+		return
+	}
+	if msg := validateTitle(in.Title); msg != "" {
+		writeValidationError(w, r, validationError{{"title", msg}})
+		return
+	}
+
+	now := time.Now()
+	a, err := s.storeOf(r).Update(id, title, func(a *article) error {
+		err := checkIfMatch(r, a)
+		if err != nil {
+			return err
+		}
+		if a.Title != in.Title {
+			a.Title = in.Title
+			a.Updated = &now
+		}
+		return nil
+	})
+	if err == errUnknownID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errTitleExists {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err == errPreconditionFailed {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	w.Header().Set("ETag", articleETag(a))
+	writeArticle(w, r, a, nil)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// setupReporting sends the server errors to the Sentry DSN of cfg.
+func setupReporting(cfg *config) error {
+	return sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.SentryDSN,
+		Release:          "blog-api@" + version,
+		AttachStacktrace: true,
+	})
+}
+
+// failure is the first error logged while serving a request, sent with
+// its server error.
+type failure struct {
+	mu  sync.Mutex
+	msg string
+	err error
+}
+
+// failureKey is the context key of the failure of a request.
+type failureKey struct{}
+
+// note records the error logged by rec, unless one was already.
+func (f *failure) note(rec slog.Record) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.msg != "" {
+		return
+	}
+	f.msg = rec.Message
+	rec.Attrs(func(a slog.Attr) bool {
+		if err, ok := a.Value.Any().(error); ok && a.Key == "err" {
+			f.err = err
+			return false
+		}
+		return true
+	})
+}
+
+// error returns the failure as an error, nil when nothing was logged.
+func (f *failure) error() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.err != nil:
+		return fmt.Errorf("%s: %w", f.msg, f.err)
+	case f.msg != "":
+		return fmt.Errorf("%s", f.msg)
+	}
+	return nil
+}
+
+// reportHandler notes the errors logged while serving a request, for
+// reportMiddleware to send them.
+type reportHandler struct {
+	slog.Handler
+}
+
+func (h reportHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if f, ok := ctx.Value(failureKey{}).(*failure); ok && rec.Level >= slog.LevelError {
+		f.note(rec)
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h reportHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return reportHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h reportHandler) WithGroup(name string) slog.Handler {
+	return reportHandler{h.Handler.WithGroup(name)}
+}
+
+// reportedRequest returns r as sent to Sentry, without its credentials.
+func reportedRequest(r *http.Request) *sentry.Request {
+	req := sentry.NewRequest(r)
+	for _, k := range []string{"X-Api-Key", csrfHeader, "Cookie", "Authorization"} {
+		delete(req.Headers, http.CanonicalHeaderKey(k))
+	}
+	req.Cookies = ""
+	return req
+}
+
+// reportMiddleware sends the panics and the server errors to Sentry,
+// with the request, its ID and the error logged while serving it. The
+// panics are raised again once sent.
+func reportMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub := sentry.CurrentHub().Clone()
+		req := reportedRequest(r)
+		hub.Scope().AddEventProcessor(func(e *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+			e.Request = req
+			return e
+		})
+		hub.Scope().SetTag("request_id", requestIDOf(r.Context()))
+		f := &failure{}
+		ctx := sentry.SetHubOnContext(r.Context(), hub)
+		ctx = context.WithValue(ctx, failureKey{}, f)
+		defer func() {
+			if v := recover(); v != nil {
+				if v != http.ErrAbortHandler {
+					hub.RecoverWithContext(ctx, v)
+				}
+				panic(v)
+			}
+		}()
+
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+		if sw.status < 500 {
+			return
+		}
+		hub.Scope().SetTag("status", strconv.Itoa(sw.status))
+		if err := f.error(); err != nil {
+			hub.CaptureException(err)
+			return
+		}
+		if sw.status == http.StatusServiceUnavailable {
+			// The server is unavailable on purpose, e.g. during
+			// maintenance, unless it logged an error.
+			return
+		}
+		hub.CaptureMessage(fmt.Sprintf("%s %s: %d %s", r.Method, r.URL.Path, sw.status, http.StatusText(sw.status)))
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the ID of a request, sent by the client or
+// generated by the server.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the length of the longest ID accepted from a
+// client.
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key of the ID of a request.
+type requestIDKey struct{}
+
+// requestIDOf returns the ID of the request of ctx, empty when none.
+func requestIDOf(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether the ID sent by a client can be kept, it
+// must be short printable ASCII to end up in the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDMiddleware gives an ID to every request, the one sent by the
+// client if valid. The ID is sent back in the X-Request-ID header and
+// added to the logs of the request.
+func requestIDMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// requestIDHandler adds the ID of the request, and of its trace when
+// traced, to the records logged with its context.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if id := requestIDOf(ctx); id != "" {
+		rec.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		rec.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
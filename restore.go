@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/boltdb/bolt"
+)
+
+// restorer is implemented by the stores able to replace their data with
+// a snapshot written by Backup.
+type restorer interface {
+	// RestoreBackup validates the snapshot read from r and swaps it with
+	// the data of the store, it returns the size of the snapshot.
+	RestoreBackup(r io.Reader) (int64, error)
+}
+
+var errRestoreUnsupported = errors.New("restore not supported by the store")
+
+// RestoreBackup writes the snapshot next to the database, validates it,
+// applies the missing migrations, then renames it over the database and
+// reopens it. Requests are blocked during the swap only.
+func (s *boltStore) RestoreBackup(r io.Reader) (int64, error) {
+	if s.readOnly {
+		return 0, errors.New("database is read-only")
+	}
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return 0, err
+	}
+	// The rename is atomic in the directory of the database.
+	f, err := ioutil.TempFile(filepath.Dir(s.path), ".restore-")
+	if err != nil {
+		return 0, err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	n, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = f.Chmod(fi.Mode())
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	err = s.validateSnapshot(tmp)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.db.Close()
+	if err != nil {
+		return 0, err
+	}
+	err = os.Rename(tmp, s.path)
+	// Reopen the database even if the rename failed, the old file is
+	// still in place then.
+	db, openErr := bolt.Open(s.path, 0666, &bolt.Options{Timeout: lockTimeout})
+	if openErr != nil {
+		return 0, openErr
+	}
+	s.db = db
+	return n, err
+}
+
+// validateSnapshot checks the snapshot at path is a consistent database
+// of blog-api whose records are decoded with the key of s, and applies
+// its missing migrations. The invalid snapshots are an invalidError.
+func (s *boltStore) validateSnapshot(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	// Bolt opens an empty file as a new database.
+	if fi.Size() == 0 {
+		return invalidError("invalid snapshot: empty file")
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return invalidError("invalid snapshot: " + err.Error())
+	}
+	snapshot := &boltStore{path: path, db: db, aead: s.aead}
+	defer db.Close()
+
+	var version uint64
+	err = db.View(func(tx *bolt.Tx) error {
+		version = schemaVersion(tx)
+		// The errors are all read for the check to end.
+		var first error
+		for err := range tx.Check() {
+			if first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+	if err != nil {
+		return invalidError("invalid snapshot: " + err.Error())
+	}
+	last := migrations[len(migrations)-1].version
+	if version == 0 {
+		return invalidError("invalid snapshot: not a database of blog-api")
+	}
+	if version > last {
+		return invalidError(fmt.Sprintf("invalid snapshot: schema version %d is newer than %d", version, last))
+	}
+	err = snapshot.migrate()
+	if err != nil {
+		return invalidError("invalid snapshot: " + err.Error())
+	}
+	corrupt, err := snapshot.Verify(false)
+	if err != nil {
+		return err
+	}
+	if len(corrupt) > 0 {
+		c := corrupt[0]
+		return invalidError(fmt.Sprintf("invalid snapshot: %d records cannot be decoded, e.g. %s of %s: %s", len(corrupt), c.Key, c.ID, c.Error))
+	}
+	return nil
+}
+
+// restoreSnapshot replaces the data of store with the snapshot read from
+// r, then rebuilds the search index unless idx is nil.
+func restoreSnapshot(store Store, idx *searchIndex, r io.Reader) (int64, error) {
+	rs, ok := baseStore(store).(restorer)
+	if !ok {
+		return 0, errRestoreUnsupported
+	}
+	n, err := rs.RestoreBackup(r)
+	if err != nil || idx == nil {
+		return n, err
+	}
+	err = idx.clear()
+	if err == nil {
+		_, err = idx.reindex(store)
+	}
+	if err != nil {
+		return n, fmt.Errorf("search index: %v", err)
+	}
+	return n, nil
+}
+
+func (s *server) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := baseStore(s.store).(restorer); !ok {
+		writeError(w, http.StatusNotImplemented, errRestoreUnsupported.Error())
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/octet-stream" {
+		writeError(w, http.StatusBadRequest, "invalid content-type")
+		return
+	}
+
+	n, err := restoreSnapshot(s.store, s.index, r.Body)
+	if e, ok := err.(invalidError); ok {
+		writeError(w, http.StatusBadRequest, e.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to restore DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to restore DB")
+		return
+	}
+	slog.WarnContext(r.Context(), "restored DB", "size", n)
+	writeResponse(w, r, struct {
+		Size int64 `json:"size"`
+	}{
+		Size: n,
+	})
+}
+
+// restoreCommand replaces the database with the snapshot of args, the
+// server must be stopped.
+func restoreCommand(cfg *config, args []string) {
+	if len(args) == 0 {
+		fatal("missing snapshot file")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fatal("fail to open snapshot", "err", err)
+	}
+	defer f.Close()
+	store, index, err := openIndexedStore(cfg)
+	if err != nil {
+		fatal("fail to open DB", "err", err)
+	}
+	defer store.Close()
+	n, err := restoreSnapshot(store, index, f)
+	if err != nil {
+		fatal("fail to restore DB", "err", err)
+	}
+	slog.Info("restored DB", "size", n)
+}
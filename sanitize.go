@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// autolinkRegexp matches the Markdown autolinks, which look like tags
+// to an HTML tokenizer.
+var autolinkRegexp = regexp.MustCompile(`^<((https?|ftp|mailto):[^\s<>"]*|[^\s<>"@/]+@[^\s<>"@/]+)>$`)
+
+// rawTextTags are the elements whose content is text up to their end
+// tag, e.g. the code of a script.
+var rawTextTags = map[string]bool{
+	"iframe": true, "noembed": true, "noframes": true, "noscript": true,
+	"plaintext": true, "script": true, "style": true, "textarea": true,
+	"title": true, "xmp": true,
+}
+
+// skipTextTags are the raw text elements removed with their content,
+// the content of the other ones is kept as text.
+var skipTextTags = map[string]bool{
+	"iframe": true, "noembed": true, "noframes": true, "noscript": true,
+	"script": true, "style": true,
+}
+
+// fenceRegexp matches the lines opening or closing a fenced code block.
+var fenceRegexp = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+
+// sanitizeContent removes from the Markdown content the HTML which
+// could run in a browser: the tags go through htmlPolicy like the
+// rendered content, the scripts and the styles are removed. The text,
+// the code blocks and spans and the autolinks are kept as written, so
+// that clients inserting the stored content in a page are safe too.
+func sanitizeContent(content string) string {
+	if !strings.ContainsRune(content, '<') {
+		return content
+	}
+	buf := &bytes.Buffer{}
+	lines := strings.SplitAfter(content, "\n")
+	for i := 0; i < len(lines); {
+		// A fenced block is kept when it is closed, since its tags are
+		// rendered as code.
+		if m := fenceRegexp.FindStringSubmatch(lines[i]); m != nil {
+			end := closingFence(lines[i+1:], m[1])
+			if end >= 0 {
+				for _, l := range lines[i : i+end+2] {
+					buf.WriteString(l)
+				}
+				i += end + 2
+				continue
+			}
+		}
+		j := i + 1
+		for j < len(lines) && !fenceRegexp.MatchString(lines[j]) {
+			j++
+		}
+		sanitizeText(buf, strings.Join(lines[i:j], ""))
+		i = j
+	}
+	return buf.String()
+}
+
+// closingFence returns the index of the line closing the fence opened
+// by fence, -1 when the block is not closed.
+func closingFence(lines []string, fence string) int {
+	for i, l := range lines {
+		m := fenceRegexp.FindStringSubmatch(l)
+		if m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) && strings.TrimSpace(l) == strings.TrimSpace(m[0]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sanitizeText writes the Markdown text s to buf, the code spans as
+// written and the rest through sanitizeHTML.
+func sanitizeText(buf *bytes.Buffer, s string) {
+	for s != "" {
+		start := strings.IndexByte(s, '`')
+		if start < 0 {
+			break
+		}
+		n := 1
+		for start+n < len(s) && s[start+n] == '`' {
+			n++
+		}
+		end := closingTicks(s[start+n:], n)
+		if end < 0 {
+			// Unmatched backticks are text.
+			sanitizeHTML(buf, s[:start+n])
+			s = s[start+n:]
+			continue
+		}
+		sanitizeHTML(buf, s[:start])
+		buf.WriteString(s[start : start+n+end+n])
+		s = s[start+n+end+n:]
+	}
+	sanitizeHTML(buf, s)
+}
+
+// closingTicks returns the index in s of the run of n backticks closing
+// a code span, -1 when there is none.
+func closingTicks(s string, n int) int {
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] == '`' {
+			j++
+		}
+		if j-i == n {
+			return i
+		}
+		i = j
+	}
+	return -1
+}
+
+// sanitizeHTML writes s to buf with its tags sanitized by htmlPolicy,
+// the comments removed and the text left as is.
+func sanitizeHTML(buf *bytes.Buffer, s string) {
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	rawTag := ""
+	for {
+		tt := z.Next()
+		raw := string(z.Raw())
+		switch tt {
+		case nethtml.ErrorToken:
+			// An unfinished tag is escaped, it must not end in a tag
+			// with the text following it.
+			if strings.HasPrefix(raw, "<") {
+				raw = "&lt;" + raw[1:]
+			}
+			buf.WriteString(raw)
+			return
+		case nethtml.TextToken:
+			switch {
+			case skipTextTags[rawTag]:
+			case rawTag != "":
+				buf.WriteString(html.EscapeString(raw))
+			default:
+				buf.WriteString(raw)
+			}
+			rawTag = ""
+		case nethtml.StartTagToken, nethtml.EndTagToken, nethtml.SelfClosingTagToken:
+			if autolinkRegexp.MatchString(raw) {
+				buf.WriteString(raw)
+				break
+			}
+			name, _ := z.TagName()
+			rawTag = ""
+			if tt == nethtml.StartTagToken && rawTextTags[string(name)] {
+				rawTag = string(name)
+			}
+			buf.WriteString(htmlPolicy.Sanitize(raw))
+		default:
+			// The comments and the doctypes are removed.
+			rawTag = ""
+		}
+	}
+}
+
+// sanitizeArticle sanitizes the content and the summary of a and of its
+// translations.
+func sanitizeArticle(a *article) {
+	a.Content = sanitizeContent(a.Content)
+	a.Summary = sanitizeContent(a.Summary)
+	for _, t := range a.Translations {
+		if t != nil {
+			sanitizeTranslation(t)
+		}
+	}
+}
+
+// sanitizeTranslation sanitizes the content and the summary of t.
+func sanitizeTranslation(t *translation) {
+	t.Content = sanitizeContent(t.Content)
+	t.Summary = sanitizeContent(t.Summary)
+}
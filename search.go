@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// titleWeight is the score of a term found in the title, a term found
+// in the content scores 1 per occurrence.
+const titleWeight = 10
+
+// searchTerms splits a search query into lowercase terms.
+func searchTerms(q string) []string {
+	return strings.Fields(strings.ToLower(q))
+}
+
+// score returns the relevance of a for the terms, or 0 when a term is
+// missing from both the title and the content.
+func score(a *article, terms []string) int {
+	title := strings.ToLower(a.Title)
+	content := strings.ToLower(a.Content)
+	total := 0
+	for _, t := range terms {
+		n := titleWeight*strings.Count(title, t) + strings.Count(content, t)
+		if n == 0 {
+			return 0
+		}
+		total += n
+	}
+	return total
+}
+
+// search returns the articles of user id matching all the terms and
+// the listing query, the most relevant first. Articles with the same
+// score are ordered from the newest.
+func search(store Store, id string, terms []string, q *listQuery) ([]*article, error) {
+	type result struct {
+		a     *article
+		score int
+	}
+	var results []result
+	err := store.Walk(id, byTimeDesc, nil, func(a *article) bool {
+		if !q.match(a) {
+			return true
+		}
+		if n := score(a, terms); n > 0 {
+			results = append(results, result{a, n})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	articles := make([]*article, len(results))
+	for i, r := range results {
+		articles[i] = r.a
+	}
+	return articles, nil
+}
+
+func (s *server) searchHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	terms := searchTerms(r.URL.Query().Get("q"))
+	if len(terms) == 0 {
+		writeError(w, http.StatusBadRequest, "missing q parameter")
+		return
+	}
+	q, err := parseListQuery(r, "")
+	if err == nil && q.paged {
+		err = errors.New("cursors are not supported by search")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.checkListStatus(w, r, id, q) {
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	summary, err := parseView(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if s.index != nil {
+		s.indexSearch(w, r, id, q, fields, summary)
+		return
+	}
+
+	articles, err := search(s.storeOf(r), id, terms, q)
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(articles)))
+	if q.offset < len(articles) {
+		articles = articles[q.offset:]
+	} else {
+		articles = nil
+	}
+	if len(articles) > q.limit {
+		articles = articles[:q.limit]
+	}
+	if articles == nil {
+		articles = []*article{}
+	}
+	if summary {
+		articles = summarize(articles)
+	}
+	writeResponse(w, r, jsonArticles(articles, fields))
+}
+
+// highlightedArticle is an article found in the search index with its
+// highlighted snippets.
+type highlightedArticle struct {
+	*article
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// indexSearch replies to a search with the search index, summary is
+// set when the summaries are sent instead of the contents.
+func (s *server) indexSearch(w http.ResponseWriter, r *http.Request, id string, q *listQuery, fields []string, summary bool) {
+	hits, total, err := s.index.search(id, r.URL.Query().Get("q"), q)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to search", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to search")
+		return
+	}
+	results := []interface{}{}
+	for _, h := range hits {
+		a, err := s.storeOf(r).GetByUUID(id, h.UUID)
+		if err == errUnknownID || err == errUnknownUUID || (err == nil && !q.match(a)) {
+			// The index lags behind the store.
+			total--
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+			writeError(w, http.StatusInternalServerError, "fail to access DB")
+			return
+		}
+		if summary {
+			a = summarize([]*article{a})[0]
+		}
+		if fields == nil {
+			results = append(results, &highlightedArticle{a, h.Fragments})
+			continue
+		}
+		m := fieldsMap(a, fields)
+		if len(h.Fragments) > 0 {
+			m["highlights"] = h.Fragments
+		}
+		results = append(results, m)
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeResponse(w, r, results)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// seedUsers are the names of the seeded users, the next ones are
+// numbered.
+var seedUsers = []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi"}
+
+// seedTopics are the subjects of the seeded articles, with the tags of
+// their articles.
+var seedTopics = []struct {
+	name string
+	tags []string
+}{
+	{"sourdough bread", []string{"cooking", "baking"}},
+	{"home espresso", []string{"coffee", "cooking"}},
+	{"trail running", []string{"running", "outdoors"}},
+	{"winter camping", []string{"outdoors", "travel"}},
+	{"container gardening", []string{"gardening", "home"}},
+	{"mechanical keyboards", []string{"hardware", "tools"}},
+	{"database indexes", []string{"programming", "databases"}},
+	{"code review", []string{"programming", "teams"}},
+	{"remote work", []string{"teams", "productivity"}},
+	{"film photography", []string{"photography", "hobbies"}},
+	{"budget travel", []string{"travel", "money"}},
+	{"learning Japanese", []string{"languages", "learning"}},
+	{"bike maintenance", []string{"cycling", "tools"}},
+	{"board games", []string{"games", "hobbies"}},
+	{"personal finance", []string{"money", "productivity"}},
+}
+
+// seedTitles are the templates of the titles of the seeded articles.
+var seedTitles = []string{
+	"Getting started with %s",
+	"What I learned from a year of %s",
+	"Five mistakes to avoid with %s",
+	"A beginner's guide to %s",
+	"Why %s is worth your time",
+	"Notes on %s",
+	"The tools I use for %s",
+	"%s on a budget",
+	"How %s changed my weekends",
+	"Frequently asked questions about %s",
+}
+
+// seedSentences are the templates of the sentences of the seeded
+// articles.
+var seedSentences = []string{
+	"When I first tried %s, I had no idea how much there was to learn.",
+	"The best advice I got about %s was to start small and stay consistent.",
+	"Most guides about %s skip the boring parts, which are the ones that matter.",
+	"After a few months, %s became part of my routine.",
+	"You do not need expensive gear to enjoy %s.",
+	"I keep a notebook with everything that went wrong with %s.",
+	"The community around %s is friendly and happy to answer questions.",
+	"It took me a while to understand why people care so much about %s.",
+	"If you only remember one thing about %s, make it patience.",
+	"My first attempts at %s were disappointing, and that is fine.",
+	"There are many opinions about %s, try a few and keep what works.",
+	"Measuring my progress with %s kept me motivated.",
+}
+
+// seedArticle returns a random article about one of the topics, written
+// at t.
+func seedArticle(rnd *rand.Rand, t time.Time) *article {
+	topic := seedTopics[rnd.Intn(len(seedTopics))]
+	title := fmt.Sprintf(seedTitles[rnd.Intn(len(seedTitles))], topic.name)
+	title = strings.ToUpper(title[:1]) + title[1:]
+
+	var content strings.Builder
+	paragraphs := 2 + rnd.Intn(4)
+	for i := 0; i < paragraphs; i++ {
+		if i > 0 && rnd.Intn(3) == 0 {
+			fmt.Fprintf(&content, "## Part %d\n\n", i)
+		}
+		sentences := 3 + rnd.Intn(4)
+		for j := 0; j < sentences; j++ {
+			if j > 0 {
+				content.WriteString(" ")
+			}
+			fmt.Fprintf(&content, seedSentences[rnd.Intn(len(seedSentences))], topic.name)
+		}
+		content.WriteString("\n\n")
+	}
+
+	a := &article{
+		Title:    title,
+		Content:  strings.TrimSpace(content.String()),
+		Tags:     topic.tags[:1+rnd.Intn(len(topic.tags))],
+		Language: "en",
+	}
+	// Some articles are still drafts.
+	if rnd.Intn(10) == 0 {
+		a.Status = statusDraft
+	}
+	return a
+}
+
+// seedCommand fills the database with random articles, written over the
+// past year, for demos and the development of the clients.
+func seedCommand(cfg *config, args []string) {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	users := fs.Int("users", 3, "number of users")
+	articles := fs.Int("articles", 50, "number of articles of each user")
+	seed := fs.Int64("seed", 0, "seed of the random articles, random when 0")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fatal("too many arguments", "command", "seed", "args", fs.Args())
+	}
+	if *users <= 0 || *articles <= 0 {
+		fatal("invalid number of users or articles")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	store, _, err := openIndexedStore(cfg)
+	if err != nil {
+		fatal("fail to open DB", "err", err)
+	}
+	defer store.Close()
+	rnd := rand.New(rand.NewSource(*seed))
+	now := time.Now()
+	n := 0
+	for i := 0; i < *users; i++ {
+		id := fmt.Sprintf("user%d", i+1)
+		if i < len(seedUsers) {
+			id = seedUsers[i]
+		}
+		batch := make([]*article, 0, *articles)
+		titles := make(map[string]int)
+		for j := 0; j < *articles; j++ {
+			t := now.Add(-time.Duration(rnd.Int63n(int64(365 * 24 * time.Hour))))
+			a := seedArticle(rnd, t)
+			// The titles identify the articles of a user.
+			titles[a.Title]++
+			if k := titles[a.Title]; k > 1 {
+				a.Title = fmt.Sprintf("%s, part %d", a.Title, k)
+			}
+			err = prepareArticle(a, t)
+			if err != nil {
+				fatal("invalid seed article", "err", err)
+			}
+			batch = append(batch, a)
+		}
+		err = store.PutAll(id, batch)
+		if err != nil {
+			fatal("fail to store articles", "err", err, "id", id)
+		}
+		n += len(batch)
+	}
+	slog.Info("seeded articles", "users", *users, "count", n, "seed", *seed)
+}
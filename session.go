@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sessionTTL is how long a session lasts, a session opened with a
+	// token ends with it.
+	sessionTTL = 12 * time.Hour
+
+	sessionCookie = "blog_session"
+	// csrfCookie holds the CSRF token, readable by the scripts of the
+	// pages for them to send it in the csrfHeader.
+	csrfCookie = "blog_csrf"
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token"
+)
+
+// session is a login of a browser.
+type session struct {
+	identity
+	csrf    string
+	expires time.Time
+}
+
+// checkCSRF reports whether token is the CSRF token of s.
+func (s *session) checkCSRF(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.csrf)) == 1
+}
+
+// sessionStore keeps the sessions in memory by hash of their ID, they
+// end when the server stops.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]*session)}
+}
+
+// create opens a session for who and returns its ID.
+func (s *sessionStore) create(who *identity, now time.Time) (string, *session, error) {
+	id, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	sess := &session{
+		identity: *who,
+		csrf:     csrf,
+		expires:  now.Add(sessionTTL),
+	}
+	if !who.expires.IsZero() && who.expires.Before(sess.expires) {
+		sess.expires = who.expires
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.sessions {
+		if now.After(v.expires) {
+			delete(s.sessions, k)
+		}
+	}
+	s.sessions[hashKey(id)] = sess
+	return id, sess, nil
+}
+
+// get returns the session of r, nil when there is none.
+func (s *sessionStore) get(r *http.Request) *session {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[hashKey(c.Value)]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.sessions, hashKey(c.Value))
+		return nil
+	}
+	return sess
+}
+
+// delete ends the session of r.
+func (s *sessionStore) delete(r *http.Request) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, hashKey(c.Value))
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// setCookie sets a cookie only sent over HTTPS, or to localhost, with
+// the requests to the API r was sent to.
+func setCookie(w http.ResponseWriter, r *http.Request, name, value string, httpOnly bool, expires time.Time) {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     linkPath(r, "/"),
+		Secure:   true,
+		HttpOnly: httpOnly,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		c.MaxAge = -1
+	} else if !expires.IsZero() {
+		c.Expires = expires
+	}
+	http.SetCookie(w, c)
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Blog API login</title>
+<style>
+body { max-width: 30em; margin: 2em auto; padding: 0 1em; font-family: sans-serif; color: #222; }
+label, input, button { display: block; margin: 0.5em 0; }
+input[type=password] { width: 100%; }
+.error { color: #c22; }
+</style>
+</head>
+<body>
+<h1>Blog API</h1>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+{{if .LoggedIn}}<p>Logged in as {{with .User}}<strong>{{.}}</strong>{{else}}{{if .Admin}}admin{{else}}API key{{end}}{{end}}.
+<a href="docs/">Explore the API</a>.</p>
+<form method="post" action="logout">
+<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+<button type="submit">Log out</button>
+</form>
+{{else}}<form method="post" action="login">
+<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+<input type="hidden" name="next" value="{{.Next}}">
+<label for="key">API key or token</label>
+<input id="key" name="key" type="password" autocomplete="current-password" required autofocus>
+<button type="submit">Log in</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// loginPage holds the data of loginTemplate.
+type loginPage struct {
+	Error string
+	CSRF  string
+	Next  string
+	// LoggedIn, Admin and User describe the current session.
+	LoggedIn bool
+	Admin    bool
+	User     string
+}
+
+func writeLoginPage(w http.ResponseWriter, status int, page *loginPage) {
+	buf := &bytes.Buffer{}
+	err := loginTemplate.Execute(buf, page)
+	if err != nil {
+		slog.Error("rendering fail", "err", err)
+		writeError(w, http.StatusInternalServerError, "rendering fail")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// safeNext returns the page to go to after the login, only the paths
+// of the server are followed. The browsers skip the tabs and the line
+// breaks and read a backslash as a slash, e.g. /\t/evil.example leads to
+// //evil.example, so they are refused with the other control
+// characters.
+func safeNext(next string) string {
+	if strings.IndexFunc(next, func(r rune) bool { return r < ' ' || r == 0x7f || r == '\\' }) >= 0 {
+		return "docs/"
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil ||
+		!strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(u.Path, "//") {
+		return "docs/"
+	}
+	return next
+}
+
+// getLoginHandler renders the login form, or the logout form of the
+// current session.
+func (s *server) getLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		writeError(w, http.StatusNotFound, "authentication disabled")
+		return
+	}
+	if sess := s.auth.sessions.get(r); sess != nil {
+		writeLoginPage(w, http.StatusOK, &loginPage{
+			CSRF:     sess.csrf,
+			LoggedIn: true,
+			Admin:    sess.admin,
+			User:     sess.user,
+		})
+		return
+	}
+
+	// The login form is protected by a token matching a cookie, there
+	// is no session to hold it yet.
+	csrf, err := randomToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to generate token", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to generate token")
+		return
+	}
+	setCookie(w, r, csrfCookie, csrf, false, time.Time{})
+	writeLoginPage(w, http.StatusOK, &loginPage{
+		CSRF: csrf,
+		Next: r.URL.Query().Get("next"),
+	})
+}
+
+// loginHandler opens a session for the key or the token of the login
+// form.
+func (s *server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		writeError(w, http.StatusNotFound, "authentication disabled")
+		return
+	}
+	c, err := r.Cookie(csrfCookie)
+	token := r.PostFormValue(csrfField)
+	if err != nil || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.Value)) != 1 {
+		writeError(w, http.StatusForbidden, "invalid CSRF token")
+		return
+	}
+
+	page := &loginPage{CSRF: c.Value, Next: r.PostFormValue("next")}
+	who, err := s.auth.identify(r.PostFormValue("key"))
+	if e, ok := err.(*authError); ok {
+		page.Error = e.msg
+		writeLoginPage(w, e.status, page)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	id, sess, err := s.auth.sessions.create(who, time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to create session", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to create session")
+		return
+	}
+	setCookie(w, r, sessionCookie, id, true, sess.expires)
+	setCookie(w, r, csrfCookie, sess.csrf, false, sess.expires)
+	http.Redirect(w, r, safeNext(page.Next), http.StatusSeeOther)
+}
+
+// logoutHandler ends the current session.
+func (s *server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		writeError(w, http.StatusNotFound, "authentication disabled")
+		return
+	}
+	sess := s.auth.sessions.get(r)
+	if sess == nil {
+		http.Redirect(w, r, "login", http.StatusSeeOther)
+		return
+	}
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.PostFormValue(csrfField)
+	}
+	if !sess.checkCSRF(token) {
+		writeError(w, http.StatusForbidden, "invalid CSRF token")
+		return
+	}
+
+	s.auth.sessions.delete(r)
+	setCookie(w, r, sessionCookie, "", true, time.Time{})
+	setCookie(w, r, csrfCookie, "", false, time.Time{})
+	http.Redirect(w, r, "login", http.StatusSeeOther)
+}
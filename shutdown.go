@@ -0,0 +1,37 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// flushTimeout is how long the traces and the error reports are given to
+// be sent on shutdown.
+const flushTimeout = 5 * time.Second
+
+// jobs runs the background jobs of the server until stopped.
+type jobs struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newJobs() *jobs {
+	return &jobs{stop: make(chan struct{})}
+}
+
+// start runs job in the background, it must return once its stop
+// channel is closed.
+func (j *jobs) start(job func(stop <-chan struct{})) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		job(j.stop)
+	}()
+}
+
+// shutdown stops the jobs and waits for them to return, e.g. for a
+// backup to be written.
+func (j *jobs) shutdown() {
+	close(j.stop)
+	j.wg.Wait()
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// siteIndexTemplate renders the home page of a static site, the list of
+// the blogs.
+var siteIndexTemplate = template.Must(template.New("site").Funcs(template.FuncMap{
+	"path": url.PathEscape,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Blogs</title>
+<style>
+body { max-width: 42em; margin: 2em auto; padding: 0 1em; font-family: Georgia, serif; line-height: 1.6; color: #222; }
+a { color: #0645ad; }
+</style>
+</head>
+<body>
+<h1>Blogs</h1>
+<ul>
+{{range .}}<li><a href="blog/{{path .}}/">Articles of {{.}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemap struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// siteWriter writes the files of a static site.
+type siteWriter struct {
+	dir string
+	// base is the URL the site is hosted at, without trailing slash.
+	base  string
+	files int
+}
+
+// write writes data to the file name of the site, creating its
+// directory.
+func (w *siteWriter) write(name string, data []byte) error {
+	path := filepath.Join(w.dir, filepath.FromSlash(name))
+	// The names hold the user IDs and the slugs, none may lead out of
+	// the directory.
+	rel, err := filepath.Rel(w.dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("file %q out of the site directory", name)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		return err
+	}
+	w.files++
+	return nil
+}
+
+// writeXML writes v encoded in XML to the file name.
+func (w *siteWriter) writeXML(name string, v interface{}) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.write(name, append([]byte(xml.Header), data...))
+}
+
+// writePage renders the blog template tmpl with page to the file name.
+func (w *siteWriter) writePage(name, tmpl string, page *blogPage) error {
+	buf := &bytes.Buffer{}
+	err := blogTemplates.ExecuteTemplate(buf, tmpl, page)
+	if err != nil {
+		return err
+	}
+	return w.write(name, buf.Bytes())
+}
+
+// writeIndex writes the pages of the index of the articles of user id,
+// restricted to tag when set. The first page is named first, the next
+// ones are numbered in the directory dir, both relative to the blog.
+func (w *siteWriter) writeIndex(id, tag, first, dir string, articles []*article, tagFiles map[string]string) error {
+	for n := 1; n == 1 || (n-1)*blogPageSize < len(articles); n++ {
+		page := &blogPage{
+			ID:       id,
+			Title:    "Articles of " + id,
+			Tag:      tag,
+			Prev:     n - 1,
+			tagFiles: tagFiles,
+		}
+		end := n * blogPageSize
+		if end < len(articles) {
+			page.Next = n + 1
+		} else {
+			end = len(articles)
+		}
+		page.Articles = articles[(n-1)*blogPageSize : end]
+		name := first
+		if n > 1 {
+			name = dir + "/" + strconv.Itoa(n) + ".html"
+		}
+		page.Base = strings.Repeat("../", strings.Count(name, "/"))
+		err := w.writePage("blog/"+id+"/"+name, "index", page)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBlog writes the index pages, the tag pages, the articles and the
+// feeds of the published articles of user id, it returns the entries of
+// the sitemap.
+func (w *siteWriter) writeBlog(store Store, id string, now time.Time) ([]sitemapURL, error) {
+	q := &listQuery{now: now, status: statusPublished}
+	var articles []*article
+	err := store.Walk(id, byTimeDesc, nil, func(a *article) bool {
+		if q.match(a) {
+			articles = append(articles, a)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The directories of the tags are named after their slug, unique
+	// among the tags of the user.
+	tagged := make(map[string][]*article)
+	for _, a := range articles {
+		for _, t := range a.Tags {
+			tagged[t] = append(tagged[t], a)
+		}
+	}
+	tags := make([]string, 0, len(tagged))
+	for t := range tagged {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	tagFiles := make(map[string]string, len(tags))
+	taken := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		slug := uniqueSlug(t, func(slug string) bool { return taken[slug] })
+		taken[slug] = true
+		tagFiles[t] = slug
+	}
+
+	err = w.writeIndex(id, "", "index.html", "page", articles, tagFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		err = w.writeIndex(id, t, "tag/"+tagFiles[t]+"/index.html", "tag/"+tagFiles[t], tagged[t], tagFiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	home := w.base + "/blog/" + url.PathEscape(id) + "/"
+	link := func(a *article) string {
+		return home + url.PathEscape(a.Slug) + "/"
+	}
+	urls := []sitemapURL{{Loc: home}}
+	if len(articles) > 0 {
+		urls[0].LastMod = lastUpdate(articles).UTC().Format(time.RFC3339)
+	}
+	// The articles are index.html in the directory of their slug, no
+	// slug collides with the pages of the index then.
+	for _, a := range articles {
+		err = w.writePage("blog/"+id+"/"+a.Slug+"/index.html", "article", &blogPage{
+			ID:       id,
+			Title:    a.Title,
+			Lang:     a.Language,
+			Article:  a,
+			Content:  template.HTML(renderMarkdown(a.Content)),
+			Base:     "../",
+			tagFiles: tagFiles,
+		})
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, sitemapURL{Loc: link(a), LastMod: a.lastChange().UTC().Format(time.RFC3339)})
+	}
+
+	latest := articles
+	if len(latest) > feedSize {
+		latest = latest[:feedSize]
+	}
+	err = w.writeXML("feed/"+id+"/rss.xml", newRSSFeed(id, home, latest, link))
+	if err != nil {
+		return nil, err
+	}
+	self := w.base + "/feed/" + url.PathEscape(id) + "/atom.xml"
+	err = w.writeXML("feed/"+id+"/atom.xml", newAtomFeed(id, self, home, latest, link))
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// exportSite writes the blogs of the users of store as a static site to
+// dir, hosted at base. It returns the number of files written.
+func exportSite(store Store, dir, base string, now time.Time) (int, error) {
+	w := &siteWriter{dir: dir, base: base}
+	users, err := store.Users()
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(users)
+	var blogs []string
+	urls := []sitemapURL{{Loc: base + "/"}}
+	for _, id := range users {
+		// The IDs are names of directories.
+		if id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+			slog.Warn("skipped user, invalid directory name", "id", id)
+			continue
+		}
+		u, err := w.writeBlog(store, id, now)
+		if err != nil {
+			return w.files, fmt.Errorf("blog of %s: %v", id, err)
+		}
+		blogs = append(blogs, id)
+		urls = append(urls, u...)
+	}
+
+	buf := &bytes.Buffer{}
+	err = siteIndexTemplate.Execute(buf, blogs)
+	if err != nil {
+		return w.files, err
+	}
+	err = w.write("index.html", buf.Bytes())
+	if err != nil {
+		return w.files, err
+	}
+	err = w.writeXML("sitemap.xml", &sitemap{URLs: urls})
+	return w.files, err
+}
+
+// exportSiteCommand writes the published articles as a static site, to
+// be hosted anywhere as a fallback of the API.
+func exportSiteCommand(cfg *config, args []string) {
+	fs := flag.NewFlagSet("export-site", flag.ExitOnError)
+	out := fs.String("out", "public", "directory of the site")
+	base := fs.String("url", "", "URL the site is hosted at, for the feeds and the sitemap")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fatal("too many arguments", "command", "export-site", "args", fs.Args())
+	}
+	u, err := url.Parse(*base)
+	if *base == "" || err != nil || u.Scheme == "" || u.Host == "" {
+		fatal("missing or invalid -url, expected the URL the site is hosted at", "url", *base)
+	}
+
+	store := mustOpenStore(cfg)
+	defer store.Close()
+	n, err := exportSite(store, *out, strings.TrimSuffix(*base, "/"), time.Now())
+	if err != nil {
+		fatal("fail to export site", "err", err, "written", n)
+	}
+	slog.Info("exported site", "dir", *out, "files", n)
+}
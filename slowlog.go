@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// dbTimer adds up the time spent in the store while serving a request.
+type dbTimer struct {
+	mu    sync.Mutex
+	total time.Duration
+	ops   int
+}
+
+// dbTimerKey is the context key of the dbTimer of a request.
+type dbTimerKey struct{}
+
+// dbTimerOf returns the timer of the request of ctx, nil when the request
+// is not timed.
+func dbTimerOf(ctx context.Context) *dbTimer {
+	t, _ := ctx.Value(dbTimerKey{}).(*dbTimer)
+	return t
+}
+
+// add records an operation on the store which took d.
+func (t *dbTimer) add(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total += d
+	t.ops++
+}
+
+// time returns the time spent in the store and the number of operations.
+func (t *dbTimer) time() (time.Duration, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total, t.ops
+}
+
+// slowRequestMiddleware logs a warning for the requests taking threshold
+// or more, with their route and the time spent in the store.
+func slowRequestMiddleware(h http.Handler, router *mux.Router, threshold time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		timer := &dbTimer{}
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), dbTimerKey{}, timer)))
+		d := time.Since(start)
+		if d < threshold {
+			return
+		}
+		route := ""
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			route, _ = match.Route.GetPathTemplate()
+		}
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		db, ops := timer.time()
+		slog.WarnContext(r.Context(), "slow request",
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"query", r.URL.RawQuery,
+			"status", sw.status,
+			"duration_ms", float64(d)/float64(time.Millisecond),
+			"db_ms", float64(db)/float64(time.Millisecond),
+			"db_ops", ops,
+		)
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxSlugLen is the maximum length of a slug in bytes, without the
+// suffix added on collisions.
+const maxSlugLen = 80
+
+// foldedRunes holds the transliteration of the letters which are not
+// decomposed into an ASCII letter and a diacritic.
+var foldedRunes = map[rune]string{
+	'æ': "ae", 'ð': "d", 'đ': "d", 'ħ': "h", 'ı': "i", 'ł': "l",
+	'ø': "o", 'œ': "oe", 'ß': "ss", 'þ': "th",
+}
+
+// slugify returns the URL friendly form of title: the diacritics are
+// removed, the letters lowercased and the other characters replaced by
+// dashes. Letters from non-Latin scripts are kept.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range norm.NFD.String(title) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		s, ok := foldedRunes[r]
+		if !ok && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			s = string(r)
+		}
+		if s == "" {
+			dash = b.Len() > 0
+			continue
+		}
+		if b.Len()+len(s) > maxSlugLen {
+			break
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(s)
+	}
+	if b.Len() == 0 {
+		return "article"
+	}
+	return b.String()
+}
+
+// uniqueSlug returns the slug of title, suffixed with a number when
+// the slug is taken.
+func uniqueSlug(title string, taken func(slug string) bool) string {
+	base := slugify(title)
+	slug := base
+	for i := 2; taken(slug); i++ {
+		slug = base + "-" + strconv.Itoa(i)
+	}
+	return slug
+}
+
+func (s *server) getArticleBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	slug, ok := params["slug"]
+	if !ok || slug == "" {
+		writeError(w, http.StatusBadRequest, "missing slug")
+		return
+	}
+
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a, err := s.storeOf(r).GetBySlug(id, slug)
+	if err == nil && (a.expired(time.Now()) || s.hidden(r, id, a)) {
+		err = errUnknownSlug
+	}
+	if err == errUnknownID || err == errUnknownSlug {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	variant, err := localize(w, r, a)
+	if err == errUnknownLanguage {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.views.add(r, id, a.UUID)
+	if notModified(w, r, articleETag(a)) {
+		return
+	}
+	writeArticle(w, r, variant, fields)
+}
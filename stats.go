@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// articleStats summarizes the articles of a user.
+type articleStats struct {
+	Count int `json:"count"`
+	// Bytes is the size of the stored articles.
+	Bytes  int64      `json:"bytes"`
+	Oldest *time.Time `json:"oldest,omitempty"`
+	Newest *time.Time `json:"newest,omitempty"`
+}
+
+// publishedStats summarizes the articles of user id published at now,
+// decoding them unlike the stores.
+func publishedStats(store Store, id string, now time.Time) (*articleStats, error) {
+	stats := &articleStats{}
+	var err error
+	walkErr := store.Walk(id, byTitle, nil, func(a *article) bool {
+		if !a.published(now) {
+			return true
+		}
+		var data []byte
+		data, err = encodeRecord(a)
+		if err != nil {
+			return false
+		}
+		stats.Count++
+		stats.Bytes += int64(len(data))
+		t := a.Timestamp.UTC()
+		if stats.Oldest == nil || t.Before(*stats.Oldest) {
+			stats.Oldest = &t
+		}
+		if stats.Newest == nil || t.After(*stats.Newest) {
+			stats.Newest = &t
+		}
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (s *server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	var stats *articleStats
+	var err error
+	if s.draftAccess(r, id) == nil {
+		stats, err = s.storeOf(r).Stats(id)
+	} else {
+		stats, err = publishedStats(s.storeOf(r), id, time.Now())
+	}
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	writeResponse(w, r, stats)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The statuses of an article. Articles without status, imported from
+// older exports, are published.
+const (
+	statusDraft     = "draft"
+	statusPublished = "published"
+)
+
+// checkStatus returns an invalidError unless status is a valid article
+// status.
+func checkStatus(status string) error {
+	switch status {
+	case statusDraft, statusPublished:
+		return nil
+	}
+	return invalidError("invalid status")
+}
+
+// published reports whether a is published at time t. A draft is
+// published once its publication time is past, even if the scheduler
+// did not change its status yet.
+func (a *article) published(t time.Time) bool {
+	if a.Status != statusDraft {
+		return true
+	}
+	return a.PublishAt != nil && !a.PublishAt.After(t)
+}
+
+// schedule makes a draft of a when its publication time is after now,
+// a publication time in the past is removed.
+func (a *article) schedule(now time.Time) {
+	if a.PublishAt == nil {
+		return
+	}
+	if a.PublishAt.After(now) {
+		a.Status = statusDraft
+	} else {
+		a.PublishAt = nil
+	}
+}
+
+// draftAccess returns an authError unless r may read the drafts of user
+// id. Like for their modification, the drafts are read by the admin,
+// the credentials not bound to a user and the user itself, or by anyone
+// when the authentication is off.
+func (s *server) draftAccess(r *http.Request, id string) error {
+	if s.auth == nil {
+		return nil
+	}
+	_, who, err := s.auth.authenticate(r)
+	if err != nil {
+		return err
+	}
+	if who == nil {
+		return &authError{http.StatusUnauthorized, "missing API key"}
+	}
+	if who.admin || who.user == "" || who.user == id {
+		return nil
+	}
+	return &authError{http.StatusForbidden, "drafts of another user"}
+}
+
+// hidden reports whether a must be hidden from r, which is the case of
+// the unpublished articles when r may not read the drafts of user id.
+func (s *server) hidden(r *http.Request, id string, a *article) bool {
+	return !a.published(time.Now()) && s.draftAccess(r, id) != nil
+}
+
+// checkListStatus writes an error and returns false when the listing q
+// of user id includes the drafts and r may not read them.
+func (s *server) checkListStatus(w http.ResponseWriter, r *http.Request, id string, q *listQuery) bool {
+	if q.status == statusPublished {
+		return true
+	}
+	err := s.draftAccess(r, id)
+	if e, ok := err.(*authError); ok {
+		writeAuthError(w, r, e)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return false
+	}
+	return true
+}
+
+// publishInterval is the delay between two publications of the
+// scheduled articles.
+const publishInterval = time.Minute
+
+// publishScheduled publishes the drafts whose publication time is
+// past, until stop is closed.
+func publishScheduled(store Store, stop <-chan struct{}) {
+	ticker := time.NewTicker(publishInterval)
+	defer ticker.Stop()
+	for {
+		n, err := publishDue(store, time.Now())
+		if err != nil {
+			slog.Error("fail to publish scheduled articles", "err", err)
+		} else if n > 0 {
+			slog.Info("published articles", "count", n)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDue publishes the drafts whose publication time is at or
+// before t and returns how many were published.
+func publishDue(store Store, t time.Time) (int, error) {
+	due := func(a *article) bool {
+		return a.Status == statusDraft && a.PublishAt != nil && !a.PublishAt.After(t)
+	}
+	found, err := dueArticles(store, t, due)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for id, articles := range found {
+		for _, a := range articles {
+			uuid := a.UUID
+			_, err = store.Update(id, a.Title, func(a *article) error {
+				// The article may have changed since it was read.
+				if a.UUID != uuid || !due(a) {
+					return errNotDue
+				}
+				a.Status = statusPublished
+				a.PublishAt = nil
+				return nil
+			})
+			if err == errUnknownID || err == errUnknownTitle || err == errNotDue {
+				continue
+			}
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// errNotDue is returned when an article found due by the background
+// jobs changed before they could publish or expire it.
+var errNotDue = errors.New("article is not due")
+
+// publishArticleHandler publishes a draft, publishing a published
+// article does nothing.
+func (s *server) publishArticleHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	now := time.Now()
+	a, err := s.storeOf(r).Update(id, title, func(a *article) error {
+		err := checkIfMatch(r, a)
+		if err != nil {
+			return err
+		}
+		if a.Status != statusPublished {
+			a.Status = statusPublished
+			a.PublishAt = nil
+			a.Updated = &now
+		}
+		return nil
+	})
+	if err == errUnknownID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errPreconditionFailed {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	w.Header().Set("ETag", articleETag(a))
+	writeArticle(w, r, a, nil)
+}
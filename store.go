@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var (
+	errUnknownID    = errors.New("unknown ID")
+	errUnknownTitle = errors.New("unknown title")
+	errUnknownUUID  = errors.New("unknown UUID")
+	errUnknownSlug  = errors.New("unknown slug")
+	errTitleExists  = errors.New("title already exists")
+)
+
+type article struct {
+	// UUID identifies the article, it is assigned when the article is
+	// created and never changes.
+	UUID  string `json:"uuid" xml:"uuid"`
+	Title string `json:"title" xml:"title"`
+	// Slug is the URL friendly form of the title, it is unique per user
+	// and assigned when the article is created.
+	Slug    string `json:"slug" xml:"slug"`
+	Content string `json:"content" xml:"content"`
+	// Summary is a short description of the content, it is generated
+	// from the content when empty.
+	Summary string `json:"summary,omitempty" xml:"summary,omitempty"`
+	// Status is either draft or published.
+	Status string `json:"status,omitempty" xml:"status,omitempty"`
+	// PublishAt is set when a draft must be published at a given time.
+	PublishAt *time.Time `json:"publish_at,omitempty" xml:"publish_at,omitempty"`
+	// Category is the path of the category of the article.
+	Category string `json:"category,omitempty" xml:"category,omitempty"`
+	// Tags are lowercase and unique.
+	Tags []string `json:"tags,omitempty" xml:"tags>tag,omitempty"`
+	// Metadata holds custom fields set by the clients.
+	Metadata metadata `json:"metadata,omitempty" xml:"metadata,omitempty"`
+	// Language is the language code of the article, e.g. en or fr-CA.
+	Language string `json:"language,omitempty" xml:"language,omitempty"`
+	// Translations are the variants of the article in other languages.
+	Translations translations `json:"translations,omitempty" xml:"translations,omitempty"`
+	Timestamp    time.Time    `json:"timestamp" xml:"timestamp"`
+	// Updated is set when the article is modified after its creation.
+	Updated *time.Time `json:"updated,omitempty" xml:"updated,omitempty"`
+	// ExpiresAt is set when the article must be deleted at a given time.
+	ExpiresAt *time.Time `json:"expires_at,omitempty" xml:"expires_at,omitempty"`
+	// Deleted is set when the article is in the trash.
+	Deleted *time.Time `json:"deleted,omitempty" xml:"deleted,omitempty"`
+	// Reactions counts the reactions of the readers by name, they are
+	// kept by the store apart from the article.
+	Reactions reactions `json:"reactions,omitempty" xml:"reactions,omitempty"`
+	// Views is the number of views of the article in the window of the
+	// popular articles, it is not stored.
+	Views int `json:"views,omitempty" xml:"views,omitempty"`
+}
+
+// expired reports whether a expired at time t.
+func (a *article) expired(t time.Time) bool {
+	return a.ExpiresAt != nil && !a.ExpiresAt.After(t)
+}
+
+// order is the order in which articles are walked.
+type order int
+
+const (
+	byTitle order = iota
+	byTimeAsc
+	byTimeDesc
+)
+
+// timeKey returns a key sorting articles by timestamp: the seconds
+// since epoch with the sign bit flipped, the nanoseconds, then the UUID.
+func timeKey(t time.Time, uuid string) []byte {
+	k := make([]byte, timeKeyLen+len(uuid))
+	binary.BigEndian.PutUint64(k, uint64(t.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(k[8:], uint32(t.Nanosecond()))
+	copy(k[timeKeyLen:], uuid)
+	return k
+}
+
+// timeKeyLen is the length of the timestamp prefix of a timeKey.
+const timeKeyLen = 12
+
+// keyTime returns the timestamp of a key built by timeKey.
+func keyTime(k []byte) time.Time {
+	sec := int64(binary.BigEndian.Uint64(k) ^ (1 << 63))
+	nsec := int64(binary.BigEndian.Uint32(k[8:]))
+	return time.Unix(sec, nsec).UTC()
+}
+
+// walkKey returns the key of a in the order o. Walking in order o
+// visits the articles by increasing walk keys, or decreasing for
+// byTimeDesc.
+func walkKey(o order, a *article) []byte {
+	if o == byTitle {
+		return []byte(a.Title)
+	}
+	return timeKey(a.Timestamp, a.UUID)
+}
+
+// Store persists articles. Articles are grouped by user ID and
+// identified by their UUID or by their title within a user.
+type Store interface {
+	// Get returns the article title of user id.
+	Get(id, title string) (*article, error)
+	// GetByUUID returns the article of user id identified by uuid.
+	GetByUUID(id, uuid string) (*article, error)
+	// GetBySlug returns the article of user id with the given slug.
+	GetBySlug(id, slug string) (*article, error)
+	// Put creates or replaces the article of user id with the same
+	// title. The UUID and the slug of a replaced article are kept, new
+	// articles get a new UUID unless they already have one, and a new
+	// slug unless they have one not taken. The UUID and the slug are set
+	// on a.
+	Put(id string, a *article) error
+	// PutAll puts all the articles of user id at once, either all the
+	// articles are stored or none is.
+	PutAll(id string, articles []*article) error
+	// Update calls fn with the article title of user id and stores the
+	// article modified by fn, unless fn returns an error. The UUID and
+	// the slug of the article cannot be modified, changing the title
+	// fails with errTitleExists if the new title is taken.
+	Update(id, title string, fn func(a *article) error) (*article, error)
+	// Delete moves the article title of user id to the trash. When
+	// check is set, it is called with the article first and the article
+	// is only deleted if check returns nil.
+	Delete(id, title string, check func(a *article) error) error
+	// List returns all the articles of user id.
+	List(id string) ([]*article, error)
+	// Walk calls fn for each article of user id in the order o, until
+	// fn returns false. When after is set, the walk starts after the
+	// article with this walk key.
+	Walk(id string, o order, after []byte, fn func(a *article) bool) error
+	// Users returns the IDs of the users having articles.
+	Users() ([]string, error)
+	// DeleteAll moves all the articles of user id to the trash and
+	// removes the user.
+	DeleteAll(id string) error
+	// DeleteFunc moves the articles of user id for which match returns
+	// true to the trash at once, it returns the number of articles
+	// moved.
+	DeleteFunc(id string, match func(a *article) bool) (int, error)
+	// Tags returns the number of articles of user id by tag.
+	Tags(id string) (map[string]int, error)
+	// Stats returns statistics about the articles of user id.
+	Stats(id string) (*articleStats, error)
+	// Trash returns the deleted articles of user id, sorted by title
+	// and the most recently deleted first.
+	Trash(id string) ([]*article, error)
+	// Restore moves the deleted article uuid of user id out of the
+	// trash.
+	Restore(id, uuid string) error
+	// Purge permanently removes the articles deleted before t, it
+	// returns the number of articles removed.
+	Purge(before time.Time) (int, error)
+	// Category returns the category path of user id.
+	Category(id, path string) (*category, error)
+	// Categories returns the categories of user id ordered by path.
+	Categories(id string) ([]*category, error)
+	// PutCategory creates or replaces the category of user id with the
+	// same path, its parent must exist.
+	PutCategory(id string, c *category) error
+	// DeleteCategory removes the category path of user id, it fails
+	// with errCategoryInUse when the category has subcategories or
+	// articles.
+	DeleteCategory(id, path string) error
+	// Close releases the resources held by the store.
+	Close() error
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// ndjsonType is the media type of the streamed listings, one JSON
+// article per line.
+const ndjsonType = "application/x-ndjson"
+
+// listTypes are the media types of the listings. The streamed listing
+// comes last, it is only sent when asked for explicitly.
+var listTypes = append(articleTypes[:len(articleTypes):len(articleTypes)], ndjsonType)
+
+// streamBatch is the number of articles read from the store at once.
+// The batches are sent between the reads, a slow client does not hold
+// the store.
+const streamBatch = 100
+
+// streamArticles writes the articles of user id matching q as ndjson,
+// the articles are sent batch by batch instead of buffering the
+// listing. The listing is not paginated, the limit, the offset and the
+// cursor of q are ignored.
+func (s *server) streamArticles(w http.ResponseWriter, r *http.Request, id string, q *listQuery, fields []string, summary bool) {
+	// The listing is not paginated, it may outlast the write timeout.
+	clearWriteDeadline(w, r)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", ndjsonType)
+	enc := json.NewEncoder(w)
+	n := 0
+	var err error
+	var after []byte
+	for more := true; more; {
+		var batch []*article
+		read := 0
+		more = false
+		err = s.storeOf(r).Walk(id, q.order, after, func(a *article) bool {
+			if q.done(a) {
+				return false
+			}
+			if q.match(a) {
+				batch = append(batch, a)
+			}
+			read++
+			if read == streamBatch {
+				// The next batch resumes after a.
+				after, more = walkKey(q.order, a), true
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			break
+		}
+		if summary {
+			batch = summarize(batch)
+		}
+		for _, a := range batch {
+			n++
+			// An encoding error means the client is gone.
+			if enc.Encode(jsonArticle(a, fields)) != nil {
+				return
+			}
+		}
+	}
+	switch {
+	case err == nil:
+	case n > 0:
+		// The status is already sent, the listing is cut short.
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+	case err == errUnknownID:
+		writeError(w, http.StatusNotFound, err.Error())
+	default:
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// summaryWords is the number of words of the content kept in a
+// generated summary.
+const summaryWords = 50
+
+// summaryOf returns the summary of a, either the one given by the
+// client or the first words of its content.
+func summaryOf(a *article) string {
+	if a.Summary != "" {
+		return a.Summary
+	}
+	words := strings.Fields(a.Content)
+	if len(words) <= summaryWords {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:summaryWords], " ") + "…"
+}
+
+// parseView reads the view query parameter, it reports whether the
+// summaries must be sent instead of the contents.
+func parseView(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("view") {
+	case "", "full":
+		return false, nil
+	case "summary":
+		return true, nil
+	}
+	return false, errors.New("invalid view parameter")
+}
+
+// summarize returns copies of the articles where the content is
+// replaced by the summary.
+func summarize(articles []*article) []*article {
+	summaries := make([]*article, len(articles))
+	for i, a := range articles {
+		c := *a
+		c.Summary = summaryOf(a)
+		c.Content = ""
+		summaries[i] = &c
+	}
+	return summaries
+}
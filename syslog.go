@@ -0,0 +1,13 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// openSyslog returns a writer to the local syslog.
+func openSyslog() (io.WriteCloser, error) {
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "blog-api")
+}
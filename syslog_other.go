@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// openSyslog fails, there is no syslog on this system.
+func openSyslog() (io.WriteCloser, error) {
+	return nil, errors.New("syslog not supported")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// activationListeners returns the sockets passed by systemd socket
+// activation, none when the server was not activated. The variables of
+// the activation are removed so that they are not passed on.
+func activationListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	var listeners []net.Listener
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, fmt.Errorf("systemd socket %d: %v", fd, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
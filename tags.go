@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// normalizeTags lowercases the tags and removes the blank and duplicate
+// ones, keeping their order.
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if strings.IndexByte(t, 0) >= 0 {
+			return nil, invalidError("invalid tag")
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	return normalized, nil
+}
+
+// hasTag reports whether a is tagged with tag.
+func (a *article) hasTag(tag string) bool {
+	for _, t := range a.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// tagKey returns the key of the tag index entry of the article uuid,
+// the tag and the UUID are separated by a zero byte.
+func tagKey(tag, uuid string) []byte {
+	return []byte(tag + "\x00" + uuid)
+}
+
+// publishedTags counts the tags of the articles of user id published at
+// now, a tag used by drafts only is not listed.
+func publishedTags(store Store, id string, now time.Time) (map[string]int, error) {
+	tags := make(map[string]int)
+	err := store.Walk(id, byTitle, nil, func(a *article) bool {
+		if a.published(now) {
+			for _, t := range a.Tags {
+				tags[t]++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// tagCount is the number of articles with a tag.
+type tagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+func (s *server) getTagsHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	var counts map[string]int
+	var err error
+	if s.draftAccess(r, id) == nil {
+		counts, err = s.storeOf(r).Tags(id)
+	} else {
+		counts, err = publishedTags(s.storeOf(r), id, time.Now())
+	}
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	tags := make([]tagCount, 0, len(counts))
+	for tag, n := range counts {
+		tags = append(tags, tagCount{tag, n})
+	}
+	// The most used tags come first.
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	writeResponse(w, r, tags)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newTLSConfig returns the TLS settings of the server: TLS 1.2 at least
+// with forward secret AEAD ciphers. With cfg.TLSClientCA, the clients
+// must present a certificate signed by one of its authorities.
+func newTLSConfig(cfg *config) (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		// TLS 1.3 suites are not configurable, these only apply to 1.2.
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	}
+	if cfg.TLSClientCA != "" {
+		data, err := ioutil.ReadFile(cfg.TLSClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("BLOG_API_TLS_CLIENT_CA: no certificate found")
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
+
+// newACMEManager returns the manager obtaining the certificates of
+// cfg.ACMEDomains from Let's Encrypt, and renewing them before they
+// expire. The certificates are kept in cfg.ACMECache.
+func newACMEManager(cfg *config) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+		Cache:      autocert.DirCache(cfg.ACMECache),
+		Email:      cfg.ACMEEmail,
+	}
+}
+
+// useACME makes c get its certificates from m, the challenges are
+// answered either over TLS or by the HTTP handler of m.
+func useACME(c *tls.Config, m *autocert.Manager) {
+	c.GetCertificate = m.GetCertificate
+	c.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+}
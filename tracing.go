@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records the spans of the server, it does nothing until
+// setupTracing is called.
+var tracer = otel.Tracer("github.com/aitva/blog-api")
+
+// setupTracing exports the spans to the OTLP endpoint of cfg, it returns
+// the function sending the last spans before exiting.
+func setupTracing(cfg *config) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName), semconv.ServiceVersion(version))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// tracingMiddleware records a span for every request, child of the span
+// of the client when it sent a traceparent header. The span is named
+// after the route of the request, e.g. GET /article/{id}/{title}/.
+func tracingMiddleware(h http.Handler, router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		attrs := []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+			semconv.ClientAddress(clientIP(r)),
+		}
+		name := r.Method
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			if route, err := match.Route.GetPathTemplate(); err == nil {
+				name += " " + route
+				attrs = append(attrs, semconv.HTTPRoute(route))
+			}
+		}
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.status))
+		if sw.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	})
+}
+
+// storeOf returns the store to use to serve r, recording its operations
+// in the trace of r when tracing is enabled and their time when r is
+// timed.
+func (s *server) storeOf(r *http.Request) Store {
+	timer := dbTimerOf(r.Context())
+	if !s.tracing && timer == nil {
+		return s.store
+	}
+	return &tracedStore{Store: s.store, ctx: r.Context(), timer: timer}
+}
+
+// tracedStore records a span for every operation on the store, child of
+// the span of ctx, and adds their time to timer unless nil.
+type tracedStore struct {
+	Store
+	ctx   context.Context
+	timer *dbTimer
+}
+
+// storeOp is an operation on a tracedStore.
+type storeOp struct {
+	span  trace.Span
+	start time.Time
+	timer *dbTimer
+}
+
+// start starts the operation op of the user id.
+func (s *tracedStore) start(op, id string) *storeOp {
+	system := "memory"
+	if _, ok := baseStore(s.Store).(*boltStore); ok {
+		system = "bolt"
+	}
+	_, span := tracer.Start(s.ctx, "store."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String(system),
+			semconv.DBOperationName(op),
+			attribute.String("blog.user_id", id),
+		),
+	)
+	return &storeOp{span: span, start: time.Now(), timer: s.timer}
+}
+
+// end ends the operation, failed when err is not nil.
+func (op *storeOp) end(err error) {
+	if op.timer != nil {
+		op.timer.add(time.Since(op.start))
+	}
+	if err != nil {
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+	}
+	op.span.End()
+}
+
+func (s *tracedStore) Get(id, title string) (a *article, err error) {
+	op := s.start("Get", id)
+	defer func() { op.end(err) }()
+	return s.Store.Get(id, title)
+}
+
+func (s *tracedStore) GetByUUID(id, uuid string) (a *article, err error) {
+	op := s.start("GetByUUID", id)
+	defer func() { op.end(err) }()
+	return s.Store.GetByUUID(id, uuid)
+}
+
+func (s *tracedStore) GetBySlug(id, slug string) (a *article, err error) {
+	op := s.start("GetBySlug", id)
+	defer func() { op.end(err) }()
+	return s.Store.GetBySlug(id, slug)
+}
+
+func (s *tracedStore) Put(id string, a *article) (err error) {
+	op := s.start("Put", id)
+	defer func() { op.end(err) }()
+	return s.Store.Put(id, a)
+}
+
+func (s *tracedStore) PutAll(id string, articles []*article) (err error) {
+	op := s.start("PutAll", id)
+	op.span.SetAttributes(attribute.Int("blog.articles", len(articles)))
+	defer func() { op.end(err) }()
+	return s.Store.PutAll(id, articles)
+}
+
+func (s *tracedStore) Update(id, title string, fn func(a *article) error) (a *article, err error) {
+	op := s.start("Update", id)
+	defer func() { op.end(err) }()
+	return s.Store.Update(id, title, fn)
+}
+
+func (s *tracedStore) Delete(id, title string, check func(a *article) error) (err error) {
+	op := s.start("Delete", id)
+	defer func() { op.end(err) }()
+	return s.Store.Delete(id, title, check)
+}
+
+func (s *tracedStore) List(id string) (articles []*article, err error) {
+	op := s.start("List", id)
+	defer func() { op.end(err) }()
+	return s.Store.List(id)
+}
+
+func (s *tracedStore) Walk(id string, o order, after []byte, fn func(a *article) bool) (err error) {
+	op := s.start("Walk", id)
+	defer func() { op.end(err) }()
+	return s.Store.Walk(id, o, after, fn)
+}
+
+func (s *tracedStore) DeleteAll(id string) (err error) {
+	op := s.start("DeleteAll", id)
+	defer func() { op.end(err) }()
+	return s.Store.DeleteAll(id)
+}
+
+func (s *tracedStore) DeleteFunc(id string, match func(a *article) bool) (n int, err error) {
+	op := s.start("DeleteFunc", id)
+	defer func() { op.end(err) }()
+	return s.Store.DeleteFunc(id, match)
+}
+
+func (s *tracedStore) Tags(id string) (counts map[string]int, err error) {
+	op := s.start("Tags", id)
+	defer func() { op.end(err) }()
+	return s.Store.Tags(id)
+}
+
+func (s *tracedStore) Stats(id string) (stats *articleStats, err error) {
+	op := s.start("Stats", id)
+	defer func() { op.end(err) }()
+	return s.Store.Stats(id)
+}
+
+func (s *tracedStore) Trash(id string) (articles []*article, err error) {
+	op := s.start("Trash", id)
+	defer func() { op.end(err) }()
+	return s.Store.Trash(id)
+}
+
+func (s *tracedStore) Restore(id, uuid string) (err error) {
+	op := s.start("Restore", id)
+	defer func() { op.end(err) }()
+	return s.Store.Restore(id, uuid)
+}
+
+func (s *tracedStore) Category(id, path string) (c *category, err error) {
+	op := s.start("Category", id)
+	defer func() { op.end(err) }()
+	return s.Store.Category(id, path)
+}
+
+func (s *tracedStore) Categories(id string) (categories []*category, err error) {
+	op := s.start("Categories", id)
+	defer func() { op.end(err) }()
+	return s.Store.Categories(id)
+}
+
+func (s *tracedStore) PutCategory(id string, c *category) (err error) {
+	op := s.start("PutCategory", id)
+	defer func() { op.end(err) }()
+	return s.Store.PutCategory(id, c)
+}
+
+func (s *tracedStore) DeleteCategory(id, path string) (err error) {
+	op := s.start("DeleteCategory", id)
+	defer func() { op.end(err) }()
+	return s.Store.DeleteCategory(id, path)
+}
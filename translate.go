@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/text/language"
+)
+
+var errUnknownLanguage = errors.New("unknown language")
+
+// translation is the variant of an article in another language.
+type translation struct {
+	Title   string `json:"title" xml:"title"`
+	Content string `json:"content" xml:"content"`
+	Summary string `json:"summary,omitempty" xml:"summary,omitempty"`
+}
+
+// translations holds the translations of an article keyed by language.
+type translations map[string]*translation
+
+// normalizeLanguage returns the canonical form of the language code
+// lang, e.g. fr-ca becomes fr-CA. An empty code is left empty.
+func normalizeLanguage(lang string) (string, error) {
+	lang = strings.TrimSpace(lang)
+	if lang == "" {
+		return "", nil
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return "", invalidError("invalid language")
+	}
+	return tag.String(), nil
+}
+
+// normalizeTranslations normalizes the language codes and rejects the
+// translations without title. It returns nil for empty translations.
+func normalizeTranslations(t translations) (translations, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	normalized := make(translations, len(t))
+	for lang, tr := range t {
+		lang, err := normalizeLanguage(lang)
+		if err != nil {
+			return nil, err
+		}
+		if lang == "" || tr == nil || tr.Title == "" {
+			return nil, invalidError("invalid translation")
+		}
+		normalized[lang] = tr
+	}
+	return normalized, nil
+}
+
+// MarshalXML encodes the translations as a list sorted by language,
+// since maps are not supported by encoding/xml.
+func (t translations) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(t) == 0 {
+		return nil
+	}
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	for _, lang := range t.languages() {
+		elem := xml.StartElement{
+			Name: xml.Name{Local: "translation"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "lang"}, Value: lang}},
+		}
+		err = e.EncodeElement(t[lang], elem)
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML decodes the translations encoded by MarshalXML.
+func (t *translations) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var list struct {
+		Translations []struct {
+			Lang string `xml:"lang,attr"`
+			translation
+		} `xml:"translation"`
+	}
+	err := d.DecodeElement(&list, &start)
+	if err != nil {
+		return err
+	}
+	for _, tr := range list.Translations {
+		if *t == nil {
+			*t = make(translations)
+		}
+		v := tr.translation
+		(*t)[tr.Lang] = &v
+	}
+	return nil
+}
+
+// languages returns the sorted languages of t.
+func (t translations) languages() []string {
+	langs := make([]string, 0, len(t))
+	for lang := range t {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// translated returns a copy of a where the title, the content and the
+// summary are replaced by their translation in lang.
+func (a *article) translated(lang string) *article {
+	t := a.Translations[lang]
+	c := *a
+	c.Language = lang
+	c.Title = t.Title
+	c.Content = t.Content
+	c.Summary = t.Summary
+	return &c
+}
+
+// localize returns the variant of a in the language asked by r, either
+// with the lang query parameter or the Accept-Language header. The
+// original article is the default variant. The variants share the ETag
+// of the stored article, which is the one checked by If-Match.
+func localize(w http.ResponseWriter, r *http.Request, a *article) (*article, error) {
+	if v, ok := r.URL.Query()["lang"]; ok {
+		lang, err := normalizeLanguage(v[0])
+		if err != nil || lang == "" {
+			return nil, invalidError("invalid lang parameter")
+		}
+		if lang != a.Language && a.Translations[lang] == nil {
+			return nil, errUnknownLanguage
+		}
+		if lang != a.Language {
+			a = a.translated(lang)
+		}
+		w.Header().Set("Content-Language", lang)
+		return a, nil
+	}
+	if len(a.Translations) == 0 {
+		if a.Language != "" {
+			w.Header().Set("Content-Language", a.Language)
+		}
+		return a, nil
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	// The original article comes first, it is the variant sent when no
+	// translation is acceptable.
+	langs := append([]string{a.Language}, a.Translations.languages()...)
+	tags := make([]language.Tag, len(langs))
+	for i, lang := range langs {
+		// The language of the original article may be unknown.
+		tags[i], _ = language.Parse(lang)
+	}
+	// An invalid header is ignored.
+	accepted, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	_, i, confidence := language.NewMatcher(tags).Match(accepted...)
+	if i > 0 && confidence != language.No {
+		a = a.translated(langs[i])
+	}
+	if a.Language != "" {
+		w.Header().Set("Content-Language", a.Language)
+	}
+	return a, nil
+}
+
+// getTranslationsHandler lists the languages of an article.
+func (s *server) getTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	a, err := s.storeOf(r).Get(id, title)
+	if err == nil && (a.expired(time.Now()) || s.hidden(r, id, a)) {
+		err = errUnknownTitle
+	}
+	if err == errUnknownID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	writeResponse(w, r, struct {
+		Language     string   `json:"language,omitempty"`
+		Translations []string `json:"translations"`
+	}{a.Language, a.Translations.languages()})
+}
+
+// putTranslationHandler creates or replaces the translation of an
+// article in a language.
+func (s *server) putTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+	lang, err := normalizeLanguage(params["lang"])
+	if err != nil || lang == "" {
+		writeError(w, http.StatusBadRequest, "invalid language")
+		return
+	}
+
+	t := &translation{}
+	err = decodeRequest(r, t)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if t.Title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+	sanitizeTranslation(t)
+
+	now := time.Now()
+	a, err := s.storeOf(r).Update(id, title, func(a *article) error {
+		err := checkIfMatch(r, a)
+		if err != nil {
+			return err
+		}
+		if lang == a.Language {
+			return invalidError("language of the original article")
+		}
+		if a.Translations == nil {
+			a.Translations = make(translations)
+		}
+		a.Translations[lang] = t
+		a.Updated = &now
+		return nil
+	})
+	if err == errUnknownID || err == errUnknownTitle {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errPreconditionFailed {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if e, ok := err.(invalidError); ok {
+		writeError(w, http.StatusBadRequest, e.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	w.Header().Set("ETag", articleETag(a))
+	w.Header().Set("Content-Language", lang)
+	writeArticle(w, r, a.translated(lang), nil)
+}
+
+// deleteTranslationHandler removes the translation of an article in a
+// language.
+func (s *server) deleteTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+	lang, err := normalizeLanguage(params["lang"])
+	if err != nil || lang == "" {
+		writeError(w, http.StatusBadRequest, "invalid language")
+		return
+	}
+
+	now := time.Now()
+	_, err = s.storeOf(r).Update(id, title, func(a *article) error {
+		err := checkIfMatch(r, a)
+		if err != nil {
+			return err
+		}
+		if a.Translations[lang] == nil {
+			return errUnknownLanguage
+		}
+		delete(a.Translations, lang)
+		if len(a.Translations) == 0 {
+			a.Translations = nil
+		}
+		a.Updated = &now
+		return nil
+	})
+	if err == errUnknownID || err == errUnknownTitle || err == errUnknownLanguage {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errPreconditionFailed {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// purgeInterval is the delay between two purges of the trash.
+const purgeInterval = time.Hour
+
+// purgeTrash permanently removes the articles staying in the trash
+// longer than retention, until stop is closed.
+func purgeTrash(store Store, retention time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		n, err := store.Purge(time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("fail to purge trash", "err", err)
+		} else if n > 0 {
+			slog.Info("purged articles", "count", n)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sortTrash sorts the deleted articles by title, the most recently
+// deleted first.
+func sortTrash(articles []*article) {
+	sort.SliceStable(articles, func(i, j int) bool {
+		a, b := articles[i], articles[j]
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return a.Deleted != nil && (b.Deleted == nil || a.Deleted.After(*b.Deleted))
+	})
+}
+
+// trashPath reports whether path is an endpoint of the trash, which is
+// read with credentials since the deleted articles, including the
+// drafts, are no longer public.
+func trashPath(path string) bool {
+	return strings.HasPrefix(path, "/trash/")
+}
+
+// trashedUUID returns the UUID of the most recently deleted article
+// title of user id.
+func trashedUUID(store Store, id, title string) (string, error) {
+	articles, err := store.Trash(id)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range articles {
+		if a.Title == title {
+			return a.UUID, nil
+		}
+	}
+	return "", errUnknownTitle
+}
+
+func (s *server) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+
+	articles, err := s.storeOf(r).Trash(id)
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	writeResponse(w, r, articles)
+}
+
+func (s *server) restoreArticleHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	title, ok := params["title"]
+	if !ok || title == "" {
+		writeError(w, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	// The article deleted last is restored, the older ones are restored
+	// by UUID.
+	uuid, err := trashedUUID(s.storeOf(r), id, title)
+	if err == nil {
+		err = s.storeOf(r).Restore(id, uuid)
+	}
+	if err == errUnknownID || err == errUnknownTitle || err == errUnknownUUID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errTitleExists {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+}
+
+func (s *server) restoreArticleByUUIDHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	uuid, ok := params["uuid"]
+	if !ok || uuid == "" {
+		writeError(w, http.StatusBadRequest, "missing UUID")
+		return
+	}
+
+	err := s.storeOf(r).Restore(id, uuid)
+	if err == errUnknownID || err == errUnknownUUID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err == errTitleExists {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+}
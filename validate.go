@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxTitleLength is the length of the longest title in characters.
+	maxTitleLength = 200
+	// maxContentLength is the size of the largest content in bytes.
+	maxContentLength = 512 << 10
+)
+
+// fieldError is the error of a field of a request.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationError lists the invalid fields of a request, it is sent
+// with 422 by writeValidationError.
+type validationError []*fieldError
+
+func (e validationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// validateTitle returns why title cannot be the title of an article,
+// an empty string when it can. The titles are keys of the store and
+// segments of the URLs.
+func validateTitle(title string) string {
+	switch {
+	case title == "":
+		return "missing"
+	case !utf8.ValidString(title):
+		return "invalid UTF-8"
+	case utf8.RuneCountInString(title) > maxTitleLength:
+		return fmt.Sprintf("longer than %d characters", maxTitleLength)
+	case strings.TrimSpace(title) != title:
+		return "leading or trailing spaces"
+	case strings.ContainsRune(title, '/'):
+		return "contains a slash"
+	case strings.IndexFunc(title, unicode.IsControl) >= 0:
+		return "contains control characters"
+	}
+	return ""
+}
+
+// validateArticle checks the title and the size of the content of an
+// article sent by a client, and of its translations.
+func validateArticle(a *article) error {
+	var errs validationError
+	if msg := validateTitle(a.Title); msg != "" {
+		errs = append(errs, &fieldError{"title", msg})
+	}
+	errs = append(errs, validateContent(a)...)
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// validateContent checks the size of the content of an article and of
+// its translations, the title is left to validateArticle.
+func validateContent(a *article) validationError {
+	var errs validationError
+	if len(a.Content) > maxContentLength {
+		errs = append(errs, &fieldError{"content", fmt.Sprintf("larger than %d bytes", maxContentLength)})
+	}
+	for _, lang := range a.Translations.languages() {
+		t := a.Translations[lang]
+		if t == nil {
+			continue
+		}
+		if utf8.RuneCountInString(t.Title) > maxTitleLength {
+			errs = append(errs, &fieldError{"translations." + lang + ".title", fmt.Sprintf("longer than %d characters", maxTitleLength)})
+		}
+		if len(t.Content) > maxContentLength {
+			errs = append(errs, &fieldError{"translations." + lang + ".content", fmt.Sprintf("larger than %d bytes", maxContentLength)})
+		}
+	}
+	return errs
+}
+
+// decodeStrict decodes the body of r into the struct pointed by v like
+// decodeRequest, the fields unknown to v are rejected with a
+// validationError. Only the codecs are strict: decodeArticle decodes
+// XML, JSON:API and protobuf without it, ignoring their unknown fields.
+func decodeStrict(r *http.Request, v interface{}) error {
+	c, err := requestCodec(r)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	_, err = buf.ReadFrom(r.Body)
+	if err == nil {
+		err = c.unmarshal(buf.Bytes(), v)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to parse %s", c.name)
+	}
+
+	// A body decoded into the struct is an object, it decodes into a
+	// map too.
+	var doc map[string]interface{}
+	err = c.unmarshal(buf.Bytes(), &doc)
+	if err != nil {
+		return fmt.Errorf("fail to parse %s", c.name)
+	}
+	fields := jsonFields(reflect.TypeOf(v).Elem())
+	var errs validationError
+	for k := range doc {
+		if _, ok := fields[k]; !ok {
+			errs = append(errs, &fieldError{k, "unknown field"})
+		}
+	}
+	if errs != nil {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		return errs
+	}
+	return nil
+}
+
+// writeValidationError sends the invalid fields of e with 422, in the
+// format asked by the client or JSON.
+func writeValidationError(w http.ResponseWriter, r *http.Request, e validationError) {
+	mediaType := negotiate(r, codecTypes)
+	if mediaType == "" {
+		mediaType = jsonCodec.mediaTypes[0]
+	}
+	data, err := codecOf(mediaType).marshal(struct {
+		Errors validationError `json:"errors"`
+	}{e})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "encoding fail", "err", err)
+		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write(data)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+)
+
+// quarantineBucket keeps the records which could not be decoded. It
+// mirrors the layout of the buckets they come from: a bucket per source
+// bucket, then a bucket per user ID.
+var quarantineBucket = []byte("_quarantine")
+
+// corruptRecord describes a record which could not be decoded.
+type corruptRecord struct {
+	Bucket string `json:"bucket"`
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Error  string `json:"error"`
+}
+
+// verifier is implemented by the stores able to check their records.
+type verifier interface {
+	// Verify decodes every record and returns the ones failing. The
+	// failing records are moved aside when quarantine is set.
+	Verify(quarantine bool) ([]*corruptRecord, error)
+}
+
+func (s *boltStore) Verify(quarantine bool) ([]*corruptRecord, error) {
+	var corrupt []*corruptRecord
+	fn := s.view
+	if quarantine {
+		fn = s.update
+	}
+	err := fn(func(tx *bolt.Tx) error {
+		corrupt = nil
+		for _, name := range [][]byte{articlesBucket, trashBucket} {
+			root := tx.Bucket(name)
+			err := root.ForEach(func(id, _ []byte) error {
+				b := root.Bucket(id)
+				var keys [][]byte
+				err := b.ForEach(func(k, v []byte) error {
+					_, err := s.unmarshal(v)
+					if err != nil {
+						corrupt = append(corrupt, &corruptRecord{
+							Bucket: string(name),
+							ID:     string(id),
+							Key:    string(k),
+							Error:  err.Error(),
+						})
+						keys = append(keys, append([]byte(nil), k...))
+					}
+					return nil
+				})
+				if err != nil || !quarantine {
+					return err
+				}
+				for _, k := range keys {
+					err = s.quarantine(tx, name, id, k)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return corrupt, nil
+}
+
+// quarantine moves the record key of user id from the bucket name to
+// the quarantine bucket and removes its index entries.
+func (s *boltStore) quarantine(tx *bolt.Tx, name, id, key []byte) error {
+	src := tx.Bucket(name).Bucket(id)
+	q, err := tx.Bucket(quarantineBucket).CreateBucketIfNotExists(name)
+	if err != nil {
+		return err
+	}
+	q, err = q.CreateBucketIfNotExists(id)
+	if err != nil {
+		return err
+	}
+	err = q.Put(key, src.Get(key))
+	if err != nil {
+		return err
+	}
+	err = src.Delete(key)
+	if err != nil || !bytes.Equal(name, articlesBucket) {
+		return err
+	}
+
+	// The record cannot be decoded, look for the index entries
+	// referencing its UUID.
+	for _, idx := range []struct {
+		root []byte
+		ref  func(k, v []byte) []byte
+	}{
+		{titleIndexBucket, func(k, v []byte) []byte { return v }},
+		{timeIndexBucket, func(k, v []byte) []byte { return k[timeKeyLen:] }},
+		{slugIndexBucket, func(k, v []byte) []byte { return v }},
+		{tagIndexBucket, func(k, v []byte) []byte { return k[bytes.IndexByte(k, 0)+1:] }},
+		{dueIndexBucket, func(k, v []byte) []byte { return k[timeKeyLen:] }},
+	} {
+		b := tx.Bucket(idx.root).Bucket(id)
+		if b == nil {
+			continue
+		}
+		var stale [][]byte
+		b.ForEach(func(k, v []byte) error {
+			if bytes.Equal(idx.ref(k, v), key) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range stale {
+			err = b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *server) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	v, ok := baseStore(s.store).(verifier)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "verification not supported by the store")
+		return
+	}
+
+	quarantine := r.URL.Query().Get("quarantine")
+	corrupt, err := v.Verify(quarantine == "1" || quarantine == "true")
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to verify DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+	if corrupt == nil {
+		corrupt = []*corruptRecord{}
+	}
+	writeResponse(w, r, corrupt)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// The build information, set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    string
+	buildDate string
+)
+
+// buildInfo is the body of the version endpoint.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// currentBuild returns the build information of the server, the commit
+// and the build date default to the ones recorded by go build.
+func currentBuild() *buildInfo {
+	b := &buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && b.Commit == "":
+			b.Commit = s.Value
+		case s.Key == "vcs.time" && b.BuildDate == "":
+			b.BuildDate = s.Value
+		}
+	}
+	return b
+}
+
+// versionHandler sends the build information of the server.
+func (s *server) versionHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(currentBuild())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "encoding fail")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// viewFlushInterval is how often the views counted in memory are
+	// written to the store, in a single transaction.
+	viewFlushInterval = 10 * time.Second
+	// defaultPopular is the number of popular articles returned when no
+	// limit is given.
+	defaultPopular = 10
+	// defaultWindow is the number of days of views of the popular
+	// articles when no window is given.
+	defaultWindow = 7
+)
+
+// viewsBucket contains a bucket per user ID, each one counting the
+// views of the user articles by day, the keys are viewDayKey and the
+// values big-endian counts.
+var viewsBucket = []byte("_views")
+
+// viewDay returns the day of t, the number of days since epoch in UTC.
+func viewDay(t time.Time) int {
+	return int(t.Unix() / (24 * 60 * 60))
+}
+
+// viewDayKey returns the key counting the views of the article uuid on
+// day, the keys of a day are contiguous.
+func viewDayKey(day int, uuid string) []byte {
+	k := make([]byte, 4+len(uuid))
+	binary.BigEndian.PutUint32(k, uint32(day))
+	copy(k[4:], uuid)
+	return k
+}
+
+// viewKey identifies the views of an article on a day.
+type viewKey struct {
+	id   string
+	uuid string
+	day  int
+}
+
+// viewStore is implemented by the stores counting the views of the
+// articles.
+type viewStore interface {
+	// AddViews adds the views counted by key.
+	AddViews(views map[viewKey]int) error
+	// Views returns the views of the articles of user id since day by
+	// UUID, all of them when day is zero.
+	Views(id string, day int) (map[string]int, error)
+}
+
+// viewCounter counts the views of the articles in memory and writes
+// them to the store periodically, the reads do not wait for a write
+// transaction.
+type viewCounter struct {
+	store   viewStore
+	mu      sync.Mutex
+	pending map[viewKey]int
+}
+
+// newViewCounter returns the counter of the views of the articles of
+// store, nil when the store cannot count them.
+func newViewCounter(store Store) *viewCounter {
+	vs, ok := baseStore(store).(viewStore)
+	if !ok {
+		return nil
+	}
+	return &viewCounter{store: vs, pending: make(map[viewKey]int)}
+}
+
+// add counts a view of the article uuid of user id when r gets it, c
+// may be nil.
+func (c *viewCounter) add(r *http.Request, id, uuid string) {
+	if c == nil || r.Method != "GET" {
+		return
+	}
+	k := viewKey{id: id, uuid: uuid, day: viewDay(time.Now())}
+	c.mu.Lock()
+	c.pending[k]++
+	c.mu.Unlock()
+}
+
+// flush writes the views counted since the last flush, they are
+// counted again on failure.
+func (c *viewCounter) flush() error {
+	c.mu.Lock()
+	views := c.pending
+	c.pending = make(map[viewKey]int)
+	c.mu.Unlock()
+	if len(views) == 0 {
+		return nil
+	}
+	err := c.store.AddViews(views)
+	if err != nil {
+		c.mu.Lock()
+		for k, n := range views {
+			c.pending[k] += n
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// run flushes the views every viewFlushInterval and once stopped.
+func (c *viewCounter) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(viewFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			err := c.flush()
+			if err != nil {
+				slog.Error("fail to write views", "err", err)
+			}
+			return
+		case <-ticker.C:
+		}
+		err := c.flush()
+		if err != nil {
+			slog.Error("fail to write views", "err", err)
+		}
+	}
+}
+
+// parseWindow reads the window parameter, a number of days such as 7d
+// or all. It returns the number of days, zero for all.
+func parseWindow(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("window")
+	switch {
+	case v == "":
+		return defaultWindow, nil
+	case v == "all":
+		return 0, nil
+	case strings.HasSuffix(v, "d"):
+		n, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+		if err == nil && n >= 1 && n <= 3660 {
+			return n, nil
+		}
+	}
+	return 0, errors.New("invalid window parameter, expected a number of days like 7d or all")
+}
+
+// rankViews returns the UUIDs of views, the most viewed first.
+func rankViews(views map[string]int) []string {
+	uuids := make([]string, 0, len(views))
+	for uuid := range views {
+		uuids = append(uuids, uuid)
+	}
+	sort.Slice(uuids, func(i, j int) bool {
+		if views[uuids[i]] != views[uuids[j]] {
+			return views[uuids[i]] > views[uuids[j]]
+		}
+		return uuids[i] < uuids[j]
+	})
+	return uuids
+}
+
+func (s *server) popularArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, ok := params["id"]
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, "missing ID")
+		return
+	}
+	vs, ok := baseStore(s.store).(viewStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "views not supported by the store")
+		return
+	}
+
+	q, err := parseListQuery(r, "")
+	if err == nil && q.paged {
+		err = errors.New("cursors are not supported by popular articles")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.checkListStatus(w, r, id, q) {
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		q.limit = defaultPopular
+	}
+	days, err := parseWindow(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	summary, err := parseView(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	since := 0
+	if days > 0 {
+		since = viewDay(q.now) - days + 1
+	}
+	views, err := vs.Views(id, since)
+	if err == nil && len(views) == 0 {
+		// The unknown users have no views either.
+		err = s.storeOf(r).Walk(id, byTitle, nil, func(*article) bool { return false })
+	}
+	articles := []*article{}
+	skip := q.offset
+	for _, uuid := range rankViews(views) {
+		if err != nil || len(articles) == q.limit {
+			break
+		}
+		var a *article
+		a, err = s.storeOf(r).GetByUUID(id, uuid)
+		if err == errUnknownUUID {
+			// The article was deleted.
+			err = nil
+			continue
+		}
+		if err != nil || !q.match(a) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		a.Views = views[uuid]
+		articles = append(articles, a)
+	}
+	if err == errUnknownID {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "fail to access DB", "err", err)
+		writeError(w, http.StatusInternalServerError, "fail to access DB")
+		return
+	}
+
+	if summary {
+		articles = summarize(articles)
+	}
+	writeResponse(w, r, jsonArticles(articles, fields))
+}
+
+// viewsOf returns the bucket of the views of user id, nil when it does
+// not exist.
+func viewsOf(tx *bolt.Tx, id []byte) *bolt.Bucket {
+	// The bucket is missing while the older migrations are applied.
+	if b := tx.Bucket(viewsBucket); b != nil {
+		return b.Bucket(id)
+	}
+	return nil
+}
+
+// deleteViews removes the views of the articles of user id whose UUID
+// is in uuids.
+func deleteViews(tx *bolt.Tx, id []byte, uuids map[string]bool) error {
+	b := viewsOf(tx, id)
+	if b == nil || len(uuids) == 0 {
+		return nil
+	}
+	var keys [][]byte
+	err := b.ForEach(func(k, _ []byte) error {
+		if uuids[string(k[4:])] {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		err = b.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *boltStore) AddViews(views map[viewKey]int) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for k, n := range views {
+			b, err := tx.Bucket(viewsBucket).CreateBucketIfNotExists([]byte(k.id))
+			if err != nil {
+				return err
+			}
+			key := viewDayKey(k.day, k.uuid)
+			count := make([]byte, 8)
+			if v := b.Get(key); len(v) == 8 {
+				n += int(binary.BigEndian.Uint64(v))
+			}
+			binary.BigEndian.PutUint64(count, uint64(n))
+			err = b.Put(key, count)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Views(id string, day int) (map[string]int, error) {
+	views := make(map[string]int)
+	err := s.view(func(tx *bolt.Tx) error {
+		b := viewsOf(tx, []byte(id))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(viewDayKey(day, "")); k != nil; k, v = c.Next() {
+			if len(k) < 4 || len(v) != 8 {
+				return fmt.Errorf("invalid view count %x", k)
+			}
+			views[string(k[4:])] += int(binary.BigEndian.Uint64(v))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+func (s *memoryStore) AddViews(views map[viewKey]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.views == nil {
+		s.views = make(map[viewKey]int)
+	}
+	for k, n := range views {
+		s.views[k] += n
+	}
+	return nil
+}
+
+func (s *memoryStore) Views(id string, day int) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	views := make(map[string]int)
+	for k, n := range s.views {
+		if k.id == id && k.day >= day {
+			views[k.uuid] += n
+		}
+	}
+	return views, nil
+}
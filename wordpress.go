@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// wxrContentSpace is the namespace of the content of the posts in a
+// WordPress export, their excerpt is an encoded element too.
+const wxrContentSpace = "http://purl.org/rss/1.0/modules/content/"
+
+// wxrTimeLayout is the layout of the dates of a WordPress export.
+const wxrTimeLayout = "2006-01-02 15:04:05"
+
+// The elements of a WordPress export (WXR) are matched by their local
+// name, the namespace of the wp elements depends on the version.
+
+type wxrEncoded struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type wxrCategory struct {
+	Domain string `xml:"domain,attr"`
+	Name   string `xml:"nicename,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type wxrItem struct {
+	Title      string        `xml:"title"`
+	PubDate    string        `xml:"pubDate"`
+	Encoded    []wxrEncoded  `xml:"encoded"`
+	Categories []wxrCategory `xml:"category"`
+	Slug       string        `xml:"post_name"`
+	Type       string        `xml:"post_type"`
+	Status     string        `xml:"status"`
+	Date       string        `xml:"post_date"`
+	DateGMT    string        `xml:"post_date_gmt"`
+}
+
+type wxrChannel struct {
+	Language string     `xml:"language"`
+	Items    []*wxrItem `xml:"item"`
+}
+
+type wxrExport struct {
+	Channel wxrChannel `xml:"channel"`
+}
+
+// date returns the time the post was published, or is scheduled, the
+// drafts may have no date.
+func (it *wxrItem) date() (time.Time, bool) {
+	if t, err := time.Parse(wxrTimeLayout, it.DateGMT); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation(wxrTimeLayout, it.Date, time.Local); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC1123Z, it.PubDate); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// article returns the article of the post, with its content in HTML,
+// which Markdown keeps. The categories are tags, but the default one.
+func (it *wxrItem) article(lang string) *article {
+	a := &article{
+		Title:    strings.TrimSpace(html.UnescapeString(it.Title)),
+		Language: lang,
+	}
+	for _, e := range it.Encoded {
+		if e.XMLName.Space == wxrContentSpace {
+			a.Content = strings.TrimSpace(e.Value)
+		} else {
+			a.Summary = strings.TrimSpace(e.Value)
+		}
+	}
+	for _, c := range it.Categories {
+		if (c.Domain == "post_tag" || c.Domain == "category") && c.Name != "uncategorized" {
+			a.Tags = append(a.Tags, html.UnescapeString(c.Value))
+		}
+	}
+	if a.Title == "" {
+		a.Title = it.Slug
+	}
+	return a
+}
+
+// wordpressArticles returns the articles of the posts of the WordPress
+// export read from r, the pages, the attachments and the posts in the
+// trash are skipped.
+func wordpressArticles(r io.Reader, now time.Time) ([]*article, error) {
+	export := &wxrExport{}
+	err := xml.NewDecoder(r).Decode(export)
+	if err != nil {
+		return nil, err
+	}
+	lang := export.Channel.Language
+	if _, err := normalizeLanguage(lang); err != nil {
+		lang = ""
+	}
+
+	var articles []*article
+	titles := make(map[string]int)
+	for _, it := range export.Channel.Items {
+		if it.Type != "post" {
+			continue
+		}
+		var status string
+		switch it.Status {
+		case "publish":
+			status = statusPublished
+		case "draft", "pending", "private", "future":
+			status = statusDraft
+		default:
+			// The automatic drafts and the posts in the trash.
+			continue
+		}
+		a := it.article(lang)
+		if a.Title == "" {
+			slog.Warn("skipped post without title", "date", it.Date)
+			continue
+		}
+		// The titles identify the articles, WordPress allows duplicates.
+		titles[a.Title]++
+		if k := titles[a.Title]; k > 1 {
+			slog.Warn("renamed post with duplicate title", "title", a.Title, "count", k)
+			a.Title = fmt.Sprintf("%s (%d)", a.Title, k)
+		}
+		t, ok := it.date()
+		if !ok {
+			t = now
+		}
+		a.Status = status
+		if it.Status == "future" && t.After(now) {
+			a.PublishAt = &t
+		}
+		err = prepareArticle(a, now)
+		if err != nil {
+			return nil, fmt.Errorf("post %q: %v", a.Title, err)
+		}
+		// The articles keep the date and the slug of the posts, the store
+		// assigns a new slug when it is taken or not canonical, e.g.
+		// percent-encoded.
+		a.Timestamp = t
+		if slugify(it.Slug) == it.Slug {
+			a.Slug = it.Slug
+		}
+		articles = append(articles, a)
+	}
+	return articles, nil
+}
+
+// importWordpressCommand stores the posts of the WordPress export of
+// args as the articles of an user.
+func importWordpressCommand(cfg *config, args []string) {
+	fs := flag.NewFlagSet("import-wordpress", flag.ExitOnError)
+	id := fs.String("user", "", "ID of the user of the articles")
+	fs.Parse(args)
+	// The flags may follow the file.
+	var path string
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+		fs.Parse(fs.Args()[1:])
+	}
+	if fs.NArg() > 0 {
+		fatal("too many arguments", "command", "import-wordpress", "args", fs.Args())
+	}
+	if path == "" || *id == "" {
+		fatal("missing export file or -user")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fatal("fail to open export", "err", err)
+	}
+	defer f.Close()
+	articles, err := wordpressArticles(f, time.Now())
+	if err != nil {
+		fatal("fail to read WordPress export", "err", err)
+	}
+	if len(articles) == 0 {
+		fatal("no posts in WordPress export", "file", path)
+	}
+	store, _, err := openIndexedStore(cfg)
+	if err != nil {
+		fatal("fail to open DB", "err", err)
+	}
+	defer store.Close()
+	err = store.PutAll(*id, articles)
+	if err != nil {
+		fatal("fail to store articles", "err", err, "id", *id)
+	}
+	slog.Info("imported WordPress posts", "id", *id, "count", len(articles))
+}